@@ -0,0 +1,471 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils"
+	harukiCredential "haruki-sekai-api/utils/credential"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var allSekaiServerRegions = []utils.HarukiSekaiServerRegion{
+	utils.HarukiSekaiServerRegionJP,
+	utils.HarukiSekaiServerRegionEN,
+	utils.HarukiSekaiServerRegionTW,
+	utils.HarukiSekaiServerRegionKR,
+	utils.HarukiSekaiServerRegionCN,
+}
+
+type adminUserView struct {
+	ID                 string              `json:"id"`
+	Remark             string              `json:"remark"`
+	RateLimitPerSecond float64             `json:"rate_limit_per_second"`
+	RateLimitBurst     int                 `json:"rate_limit_burst"`
+	DailyQuota         int64               `json:"daily_quota"`
+	Servers            []string            `json:"servers"`
+	Scopes             map[string][]string `json:"scopes,omitempty"`
+}
+
+type CreateSekaiUserRequest struct {
+	ID                 string   `json:"id"`
+	Credential         string   `json:"credential"`
+	Remark             string   `json:"remark"`
+	RateLimitPerSecond float64  `json:"rate_limit_per_second"`
+	RateLimitBurst     int      `json:"rate_limit_burst"`
+	DailyQuota         int64    `json:"daily_quota"`
+	Servers            []string `json:"servers"`
+	Scopes             []string `json:"scopes"`
+}
+
+type adminUpdateUserRequest struct {
+	Credential         *string  `json:"credential"`
+	Remark             *string  `json:"remark"`
+	RateLimitPerSecond *float64 `json:"rate_limit_per_second"`
+	RateLimitBurst     *int     `json:"rate_limit_burst"`
+	DailyQuota         *int64   `json:"daily_quota"`
+}
+
+type adminGrantServerRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+type IssueSekaiUserTokenRequest struct {
+	Credential string   `json:"credential"`
+	TTL        string   `json:"ttl"`
+	Scopes     []string `json:"scopes"`
+}
+
+func adminTokenMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if HarukiSekaiAdminToken == "" {
+			return fiber.NewError(fiber.StatusForbidden, "Admin API is not configured")
+		}
+		token := c.Get("X-Haruki-Sekai-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(HarukiSekaiAdminToken)) != 1 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid admin token")
+		}
+		return c.Next()
+	}
+}
+
+func adminDatabaseMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if HarukiSekaiUserDB == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Database is not enabled")
+		}
+		return c.Next()
+	}
+}
+
+func evictUserCache(uid string) {
+	harukiCredentialCache.evictUser(uid)
+	if HarukiSekaiRedis == nil {
+		return
+	}
+	keys := make([]string, 0, len(allSekaiServerRegions))
+	for _, region := range allSekaiServerRegions {
+		keys = append(keys, fmt.Sprintf("haruki_sekai_api:%s:%s", uid, region))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = HarukiSekaiRedis.Del(ctx, keys...).Err()
+}
+
+func parseServerList(servers []string) ([]string, error) {
+	parsed := make([]string, 0, len(servers))
+	for _, s := range servers {
+		region, err := utils.ParseSekaiServerRegion(strings.ToLower(s))
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		parsed = append(parsed, string(region))
+	}
+	return parsed, nil
+}
+
+func loadAdminUserView(db *gorm.DB, user SekaiUser) (adminUserView, error) {
+	var grants []SekaiUserServer
+	if err := db.Where("user_id = ?", user.ID).Order("server").Find(&grants).Error; err != nil {
+		return adminUserView{}, err
+	}
+	view := adminUserView{
+		ID:                 user.ID,
+		Remark:             user.Remark,
+		RateLimitPerSecond: user.RateLimitPerSecond,
+		RateLimitBurst:     user.RateLimitBurst,
+		DailyQuota:         user.DailyQuota,
+		Servers:            []string{},
+	}
+	for _, g := range grants {
+		view.Servers = append(view.Servers, g.Server)
+		if scopes := splitScopes(g.Scopes); scopes != nil {
+			if view.Scopes == nil {
+				view.Scopes = make(map[string][]string)
+			}
+			view.Scopes[g.Server] = scopes
+		}
+	}
+	return view, nil
+}
+
+func findSekaiUser(uid string) (*SekaiUser, error) {
+	var user SekaiUser
+	if err := HarukiSekaiUserDB.Where("id = ?", uid).Take(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "User not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return &user, nil
+}
+
+func adminListUsers(c fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	var users []SekaiUser
+	if err := HarukiSekaiUserDB.Order("id").Limit(limit).Offset(max(offset, 0)).Find(&users).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	views := make([]adminUserView, 0, len(users))
+	for _, u := range users {
+		view, err := loadAdminUserView(HarukiSekaiUserDB, u)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+		}
+		views = append(views, view)
+	}
+	return c.JSON(views)
+}
+
+func adminGetUser(c fiber.Ctx) error {
+	user, err := findSekaiUser(c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+	view, err := loadAdminUserView(HarukiSekaiUserDB, *user)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(view)
+}
+
+func CreateSekaiUser(req CreateSekaiUserRequest) (*SekaiUser, error) {
+	if req.ID == "" || req.Credential == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "id and credential are required")
+	}
+	servers, err := parseServerList(req.Servers)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RateLimitPerSecond < 0 || req.RateLimitBurst < 0 || req.DailyQuota < 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "rate limits must not be negative")
+	}
+	hashed, err := harukiCredential.Hash(req.Credential)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to hash credential")
+	}
+
+	user := SekaiUser{
+		ID:                 req.ID,
+		Credential:         hashed,
+		Remark:             req.Remark,
+		RateLimitPerSecond: req.RateLimitPerSecond,
+		RateLimitBurst:     req.RateLimitBurst,
+		DailyQuota:         req.DailyQuota,
+	}
+	err = HarukiSekaiUserDB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&SekaiUser{}).Where("id = ?", req.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fiber.NewError(fiber.StatusConflict, "User already exists")
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		for _, server := range servers {
+			if err := tx.Save(&SekaiUserServer{UserID: req.ID, Server: server, Scopes: scopes}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return nil, fe
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	evictUserCache(user.ID)
+	return &user, nil
+}
+
+func adminCreateUser(c fiber.Ctx) error {
+	var req CreateSekaiUserRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	user, err := CreateSekaiUser(req)
+	if err != nil {
+		return err
+	}
+
+	view, err := loadAdminUserView(HarukiSekaiUserDB, *user)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return c.Status(fiber.StatusCreated).JSON(view)
+}
+
+func adminUpdateUser(c fiber.Ctx) error {
+	user, err := findSekaiUser(c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+	var req adminUpdateUserRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	updates := map[string]any{}
+	if req.Credential != nil {
+		if *req.Credential == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "credential must not be empty")
+		}
+		hashed, err := harukiCredential.Hash(*req.Credential)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to hash credential")
+		}
+		updates["credential"] = hashed
+		updates["tokens_valid_after"] = tokensValidAfter(time.Now())
+	}
+	if req.Remark != nil {
+		updates["remark"] = *req.Remark
+	}
+	if req.RateLimitPerSecond != nil {
+		if *req.RateLimitPerSecond < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "rate_limit_per_second must not be negative")
+		}
+		updates["rate_limit_per_second"] = *req.RateLimitPerSecond
+	}
+	if req.RateLimitBurst != nil {
+		if *req.RateLimitBurst < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "rate_limit_burst must not be negative")
+		}
+		updates["rate_limit_burst"] = *req.RateLimitBurst
+	}
+	if req.DailyQuota != nil {
+		if *req.DailyQuota < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "daily_quota must not be negative")
+		}
+		updates["daily_quota"] = *req.DailyQuota
+	}
+	if len(updates) > 0 {
+		if err := HarukiSekaiUserDB.Model(&SekaiUser{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+		}
+		evictUserCache(user.ID)
+	}
+	return adminGetUser(c)
+}
+
+func adminDeleteUser(c fiber.Ctx) error {
+	uid := c.Params("user_id")
+	if _, err := findSekaiUser(uid); err != nil {
+		return err
+	}
+	err := HarukiSekaiUserDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&SekaiUserServer{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", uid).Delete(&SekaiUser{}).Error
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	evictUserCache(uid)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func GrantSekaiUserServer(uid, server string, scopes []string) error {
+	if _, err := findSekaiUser(uid); err != nil {
+		return err
+	}
+	servers, err := parseServerList([]string{server})
+	if err != nil {
+		return err
+	}
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return err
+	}
+	if err := HarukiSekaiUserDB.Save(&SekaiUserServer{UserID: uid, Server: servers[0], Scopes: normalized}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	evictUserCache(uid)
+	return nil
+}
+
+func adminGrantServer(c fiber.Ctx) error {
+	var req adminGrantServerRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+	if err := GrantSekaiUserServer(c.Params("user_id"), c.Params("server"), req.Scopes); err != nil {
+		return err
+	}
+	return adminGetUser(c)
+}
+
+func adminRevokeServer(c fiber.Ctx) error {
+	uid := c.Params("user_id")
+	if _, err := findSekaiUser(uid); err != nil {
+		return err
+	}
+	servers, err := parseServerList([]string{c.Params("server")})
+	if err != nil {
+		return err
+	}
+	if err := HarukiSekaiUserDB.Where("user_id = ? AND server = ?", uid, servers[0]).Delete(&SekaiUserServer{}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	evictUserCache(uid)
+	return adminGetUser(c)
+}
+
+func issueSekaiUserToken(user *SekaiUser, credential string, ttl time.Duration, scopes string) (string, error) {
+	if HarukiSekaiUserJWTSigningKey == nil || *HarukiSekaiUserJWTSigningKey == "" {
+		return "", fmt.Errorf("JWT secret not configured")
+	}
+	if wait := time.Until(time.Unix(user.TokensValidAfter, 0)); wait > 0 {
+		time.Sleep(wait)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"uid":        user.ID,
+		"credential": credential,
+		"iat":        now.Unix(),
+		"jti":        uuid.NewString(),
+	}
+	if ttl > 0 {
+		claims["exp"] = now.Add(ttl).Unix()
+	}
+	if scopes != "" {
+		claims["scopes"] = scopes
+	}
+	if aud := config.Cfg.Backend.JWT.Audience; aud != "" {
+		claims["aud"] = aud
+	}
+	if iss := config.Cfg.Backend.JWT.Issuer; iss != "" {
+		claims["iss"] = iss
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(*HarukiSekaiUserJWTSigningKey))
+}
+
+func IssueSekaiUserToken(uid string, req IssueSekaiUserTokenRequest) (string, error) {
+	user, err := findSekaiUser(uid)
+	if err != nil {
+		return "", err
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+			return "", fiber.NewError(fiber.StatusBadRequest, "invalid ttl")
+		}
+	}
+	credential := req.Credential
+	if credential == "" && !harukiCredential.IsHashed(user.Credential) {
+		credential = user.Credential
+	}
+	if credential == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "credential is required to issue a token")
+	}
+	if ok, _ := harukiCredential.Verify(credential, user.Credential); !ok {
+		return "", fiber.NewError(fiber.StatusBadRequest, "credential does not match")
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return "", err
+	}
+	token, err := issueSekaiUserToken(user, credential, ttl, scopes)
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return token, nil
+}
+
+func adminIssueToken(c fiber.Ctx) error {
+	var req IssueSekaiUserTokenRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+	token, err := IssueSekaiUserToken(c.Params("user_id"), req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"token": token})
+}
+
+func registerHarukiSekaiAdminRoutes(app *fiber.App) {
+	admin := app.Group("/admin", adminTokenMiddleware())
+
+	admin.Get("/health", adminGetHealth)
+	admin.Get("/health/:server", adminGetServerHealth)
+
+	db := adminDatabaseMiddleware()
+	admin.Get("/users", db, adminListUsers)
+	admin.Post("/users", db, adminCreateUser)
+	admin.Get("/users/:user_id", db, adminGetUser)
+	admin.Patch("/users/:user_id", db, adminUpdateUser)
+	admin.Delete("/users/:user_id", db, adminDeleteUser)
+	admin.Put("/users/:user_id/servers/:server", db, adminGrantServer)
+	admin.Delete("/users/:user_id/servers/:server", db, adminRevokeServer)
+	admin.Post("/users/:user_id/token", db, adminIssueToken)
+	admin.Post("/users/:user_id/revoke-tokens", db, adminRevokeUserTokens)
+	admin.Post("/tokens/revoke", db, adminRevokeToken)
+	admin.Get("/audit-logs", db, adminListAuditLogs)
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"haruki-sekai-api/client"
 	"haruki-sekai-api/utils"
 
+	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v3"
 )
 
 var digitsRe = regexp.MustCompile(`^\d+$`)
 
+const maxEventRankingRangeSize = 50
+
 func getMgr(c fiber.Ctx) (utils.HarukiSekaiServerRegion, *client.SekaiClientManager, error) {
 	region, err := utils.ParseSekaiServerRegion(strings.ToLower(c.Params("server")))
 	if err != nil {
@@ -27,15 +32,66 @@ func getMgr(c fiber.Ctx) (utils.HarukiSekaiServerRegion, *client.SekaiClientMana
 	return region, mgr, nil
 }
 
+func gameAPIContext(c fiber.Ctx) (context.Context, context.CancelFunc) {
+	var ctx context.Context = c.RequestCtx()
+	if served, ok := c.Locals("servedBy").(*client.ServedBy); ok {
+		ctx = client.WithServedBy(ctx, served)
+	}
+	return context.WithTimeout(ctx, 45*time.Second)
+}
+
 func proxyGameAPI(c fiber.Ctx, path string, params map[string]any) error {
-	_, mgr, err := getMgr(c)
+	region, mgr, err := getMgr(c)
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(c.RequestCtx(), 45*time.Second)
+	ctx, cancel := gameAPIContext(c)
 	defer cancel()
+
+	var (
+		cacheKey string
+		cacheTTL time.Duration
+		cached   bool
+	)
+	if harukiResponseCache != nil {
+		cacheTTL, cached = harukiResponseCache.ttlFor(c)
+	}
+	if cached {
+		cacheKey = responseCacheKey(string(region), path, params)
+		if !harukiResponseCache.canBypass(c) {
+			if resp, ok := harukiResponseCache.backend.get(ctx, cacheKey); ok {
+				return writeCachedResponse(c, resp, true)
+			}
+		}
+	}
+
 	data, status, _ := mgr.GetGameAPI(ctx, path, params)
-	return c.Status(status).JSON(data)
+	if !cached || status != fiber.StatusOK {
+		return c.Status(status).JSON(data)
+	}
+	body, err := sonic.Marshal(data)
+	if err != nil {
+		return c.Status(status).JSON(data)
+	}
+	now := time.Now()
+	resp := &cachedResponse{Status: status, Body: body, StoredAt: now.Unix(), ExpiresAt: now.Add(cacheTTL).Unix()}
+	harukiResponseCache.backend.set(ctx, cacheKey, resp, cacheTTL)
+	return writeCachedResponse(c, resp, false)
+}
+
+func forEachBounded(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(idx)
+		}(i)
+	}
+	wg.Wait()
 }
 
 func getUserProfile(c fiber.Ctx) error {
@@ -72,13 +128,143 @@ func getEventRankingBorder(c fiber.Ctx) error {
 	return proxyGameAPI(c, fmt.Sprintf("/event/%s/ranking-border", eventID), nil)
 }
 
+func getEventRankingByRank(c fiber.Ctx) error {
+	eventID := c.Params("event_id")
+	if !digitsRe.MatchString(eventID) {
+		return fiber.NewError(fiber.StatusBadRequest, "event_id must be numeric")
+	}
+	rank := c.Params("rank")
+	if !digitsRe.MatchString(rank) {
+		return fiber.NewError(fiber.StatusBadRequest, "rank must be numeric")
+	}
+	path := fmt.Sprintf("/user/{userId}/event/%s/ranking", eventID)
+	return proxyGameAPI(c, path, map[string]any{"targetRank": rank})
+}
+
+func getEventRankingByUser(c fiber.Ctx) error {
+	eventID := c.Params("event_id")
+	if !digitsRe.MatchString(eventID) {
+		return fiber.NewError(fiber.StatusBadRequest, "event_id must be numeric")
+	}
+	userID := c.Params("user_id")
+	if !digitsRe.MatchString(userID) {
+		return fiber.NewError(fiber.StatusBadRequest, "user_id must be numeric")
+	}
+	path := fmt.Sprintf("/user/{userId}/event/%s/ranking", eventID)
+	return proxyGameAPI(c, path, map[string]any{"targetUserId": userID})
+}
+
+func eventChapterRankingPaths(region utils.HarukiSekaiServerRegion, eventID, chapterID string) (string, string) {
+	switch region {
+	case utils.HarukiSekaiServerRegionJP, utils.HarukiSekaiServerRegionEN:
+		return fmt.Sprintf("/user/{userId}/event/%s/world-bloom/%s/ranking?rankingViewType=top100", eventID, chapterID),
+			fmt.Sprintf("/event/%s/world-bloom/%s/ranking-border", eventID, chapterID)
+	default:
+		return fmt.Sprintf("/user/{userId}/event/%s/chapter/%s/ranking?rankingViewType=top100", eventID, chapterID),
+			fmt.Sprintf("/event/%s/chapter/%s/ranking-border", eventID, chapterID)
+	}
+}
+
+func parseEventChapterParams(c fiber.Ctx) (utils.HarukiSekaiServerRegion, string, string, error) {
+	eventID := c.Params("event_id")
+	if !digitsRe.MatchString(eventID) {
+		return "", "", "", fiber.NewError(fiber.StatusBadRequest, "event_id must be numeric")
+	}
+	chapterID := c.Params("chapter_id")
+	if !digitsRe.MatchString(chapterID) {
+		return "", "", "", fiber.NewError(fiber.StatusBadRequest, "chapter_id must be numeric")
+	}
+	region, err := utils.ParseSekaiServerRegion(strings.ToLower(c.Params("server")))
+	if err != nil {
+		return "", "", "", fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return region, eventID, chapterID, nil
+}
+
+func getEventChapterRankingTop100(c fiber.Ctx) error {
+	region, eventID, chapterID, err := parseEventChapterParams(c)
+	if err != nil {
+		return err
+	}
+	path, _ := eventChapterRankingPaths(region, eventID, chapterID)
+	return proxyGameAPI(c, path, nil)
+}
+
+func getEventChapterRankingBorder(c fiber.Ctx) error {
+	region, eventID, chapterID, err := parseEventChapterParams(c)
+	if err != nil {
+		return err
+	}
+	_, path := eventChapterRankingPaths(region, eventID, chapterID)
+	return proxyGameAPI(c, path, nil)
+}
+
+type eventRankingRangeItem struct {
+	Rank   int `json:"rank"`
+	Status int `json:"status"`
+	Data   any `json:"data"`
+}
+
+func parseEventRankingRange(c fiber.Ctx) ([]int, error) {
+	start, end, step := c.Query("start"), c.Query("end"), c.Query("step", "1")
+	if !digitsRe.MatchString(start) || !digitsRe.MatchString(end) || !digitsRe.MatchString(step) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "start, end and step must be numeric")
+	}
+	s, _ := strconv.Atoi(start)
+	e, _ := strconv.Atoi(end)
+	st, _ := strconv.Atoi(step)
+	if s < 1 || e < s || st < 1 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid ranking range")
+	}
+	if (e-s)/st+1 > maxEventRankingRangeSize {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("ranking range must not contain more than %d ranks", maxEventRankingRangeSize))
+	}
+	var ranks []int
+	for r := s; r <= e; r += st {
+		ranks = append(ranks, r)
+	}
+	return ranks, nil
+}
+
+func getEventRankingRange(c fiber.Ctx) error {
+	eventID := c.Params("event_id")
+	if !digitsRe.MatchString(eventID) {
+		return fiber.NewError(fiber.StatusBadRequest, "event_id must be numeric")
+	}
+	ranks, err := parseEventRankingRange(c)
+	if err != nil {
+		return err
+	}
+	_, mgr, err := getMgr(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := gameAPIContext(c)
+	defer cancel()
+	path := fmt.Sprintf("/user/{userId}/event/%s/ranking", eventID)
+	results := make([]eventRankingRangeItem, len(ranks))
+	forEachBounded(len(ranks), mgr.ClientCount(), func(i int) {
+		data, status, _ := mgr.GetGameAPI(ctx, path, map[string]any{"targetRank": ranks[i]})
+		results[i] = eventRankingRangeItem{Rank: ranks[i], Status: status, Data: data}
+	})
+	return c.JSON(fiber.Map{"results": results})
+}
+
 func registerHarukiSekaiAPIRoutes(app *fiber.App) {
-	api := app.Group("/api/:server", validateUserTokenMiddleware())
+	api := newRouteGroup(app, "/api/:server", true)
 
-	api.Get("/:user_id/profile", getUserProfile)
-	api.Get("/system", getSystem)
-	api.Get("/information", getInformation)
-	api.Get("/event/:event_id/ranking-top100", getEventRankingTop100)
-	api.Get("/event/:event_id/ranking-border", getEventRankingBorder)
+	api.get("/:user_id/profile", scopeProfile, getUserProfile)
+	api.post("/profiles", scopeProfile, getUserProfiles)
+	api.get("/system", scopeInformation, getSystem)
+	api.get("/information", scopeInformation, getInformation)
+	api.get("/event/:event_id/ranking-top100", scopeRanking, getEventRankingTop100)
+	api.get("/event/:event_id/ranking-border", scopeRanking, getEventRankingBorder)
+	api.get("/event/:event_id/ranking/rank/:rank", scopeRanking, getEventRankingByRank)
+	api.get("/event/:event_id/ranking/user/:user_id", scopeRanking, getEventRankingByUser)
+	api.get("/event/:event_id/ranking-range", scopeRanking, getEventRankingRange)
+	api.get("/event/:event_id/chapter/:chapter_id/ranking-top100", scopeRanking, getEventChapterRankingTop100)
+	api.get("/event/:event_id/chapter/:chapter_id/ranking-border", scopeRanking, getEventChapterRankingBorder)
+	api.get("/raw/*", scopeRaw, getRawGameAPI)
 
 }
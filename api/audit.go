@@ -0,0 +1,298 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"haruki-sekai-api/client"
+	"haruki-sekai-api/config"
+	harukiLogger "haruki-sekai-api/utils/logger"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditLogBufferSize    = 4096
+	defaultAuditLogBatchSize     = 200
+	defaultAuditLogFlushInterval = 2 * time.Second
+	maxAuditLogQueryLimit        = 500
+)
+
+type auditLogWriter struct {
+	db            *gorm.DB
+	entries       chan SekaiAuditLog
+	batchSize     int
+	flushInterval time.Duration
+	retention     time.Duration
+	logger        *harukiLogger.Logger
+	dropped       atomic.Int64
+	stop          chan struct{}
+	stopped       chan struct{}
+	stopOnce      sync.Once
+}
+
+var harukiAuditLog *auditLogWriter
+
+func newAuditLogWriter(cfg config.AuditLogConfig) (*auditLogWriter, error) {
+	w := &auditLogWriter{
+		batchSize:     defaultAuditLogBatchSize,
+		flushInterval: defaultAuditLogFlushInterval,
+		logger:        harukiLogger.NewLogger("HarukiSekaiAuditLog", "INFO", nil),
+	}
+	bufferSize := defaultAuditLogBufferSize
+	if cfg.BufferSize > 0 {
+		bufferSize = cfg.BufferSize
+	}
+	if cfg.BatchSize > 0 {
+		w.batchSize = cfg.BatchSize
+	}
+	if cfg.FlushInterval != "" {
+		d, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid audit_log flush_interval %q", cfg.FlushInterval)
+		}
+		w.flushInterval = d
+	}
+	if cfg.Retention != "" {
+		d, err := time.ParseDuration(cfg.Retention)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid audit_log retention %q", cfg.Retention)
+		}
+		w.retention = d
+	}
+	w.entries = make(chan SekaiAuditLog, bufferSize)
+	w.stop = make(chan struct{})
+	w.stopped = make(chan struct{})
+	return w, nil
+}
+
+func initAuditLog(cfg config.AuditLogConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if HarukiSekaiUserDB == nil {
+		return fmt.Errorf("audit_log requires gorm to be enabled")
+	}
+	w, err := newAuditLogWriter(cfg)
+	if err != nil {
+		return err
+	}
+	w.db = HarukiSekaiUserDB
+	go w.run()
+	harukiAuditLog = w
+	return nil
+}
+
+func (w *auditLogWriter) record(entry SekaiAuditLog) {
+	select {
+	case w.entries <- entry:
+	default:
+		if n := w.dropped.Add(1); n%1000 == 1 {
+			w.logger.Warnf("audit log buffer is full, %d records dropped so far", n)
+		}
+	}
+}
+
+func (w *auditLogWriter) flush(batch []SekaiAuditLog) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := w.db.CreateInBatches(batch, w.batchSize).Error; err != nil {
+		w.logger.Errorf("failed to write %d audit log records: %v", len(batch), err)
+	}
+}
+
+func (w *auditLogWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch := make([]SekaiAuditLog, 0, w.batchSize)
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-w.stop:
+			for {
+				select {
+				case entry := <-w.entries:
+					batch = append(batch, entry)
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+		w.flush(batch)
+		batch = make([]SekaiAuditLog, 0, w.batchSize)
+	}
+}
+
+func (w *auditLogWriter) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.stopped
+}
+
+func (w *auditLogWriter) purge() {
+	cutoff := time.Now().Add(-w.retention).Unix()
+	result := w.db.Where("created_at < ?", cutoff).Delete(&SekaiAuditLog{})
+	if result.Error != nil {
+		w.logger.Errorf("failed to purge audit logs: %v", result.Error)
+		return
+	}
+	w.logger.Infof("purged %d audit log records older than %s", result.RowsAffected, w.retention)
+}
+
+func registerAuditLogRetention(cfg config.AuditLogConfig, sch gocron.Scheduler) error {
+	if harukiAuditLog == nil || harukiAuditLog.retention == 0 {
+		return nil
+	}
+	cron := cfg.RetentionCron
+	if cron == "" {
+		cron = "0 4 * * *"
+	}
+	if _, err := sch.NewJob(gocron.CronJob(cron, false), gocron.NewTask(harukiAuditLog.purge)); err != nil {
+		return fmt.Errorf("register audit log retention failed: %w", err)
+	}
+	harukiSchedulerLogger.Infof("audit log retention registered cron: %s", cron)
+	return nil
+}
+
+func truncateAuditField(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+func auditLogMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if harukiAuditLog == nil {
+			return c.Next()
+		}
+		user, ok := c.Locals("sekaiUser").(SekaiUser)
+		if !ok {
+			return c.Next()
+		}
+		served := &client.ServedBy{}
+		c.Locals("servedBy", served)
+
+		startedAt := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		entry := SekaiAuditLog{
+			CreatedAt:      startedAt.Unix(),
+			UserID:         user.ID,
+			Server:         strings.ToLower(c.Params("server")),
+			Method:         c.Method(),
+			Route:          truncateAuditField(c.Route().Path, 255),
+			Path:           truncateAuditField(c.OriginalURL(), 512),
+			EventID:        truncateAuditField(c.Params("event_id"), 32),
+			Status:         status,
+			LatencyMs:      time.Since(startedAt).Milliseconds(),
+			ServingAccount: truncateAuditField(strings.Join(served.Accounts(), ","), 255),
+			Coalesced:      served.Coalesced(),
+			ClientIP:       c.IP(),
+		}
+		targets, _ := c.Locals("auditTargetUserIDs").([]string)
+		if len(targets) == 0 {
+			targets = []string{c.Params("user_id")}
+		}
+		for _, target := range targets {
+			entry.TargetUserID = truncateAuditField(target, 64)
+			harukiAuditLog.record(entry)
+		}
+		return err
+	}
+}
+
+func parseAuditTime(value string) (int64, error) {
+	if digitsRe.MatchString(value) {
+		return strconv.ParseInt(value, 10, 64)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func adminListAuditLogs(c fiber.Ctx) error {
+	query := HarukiSekaiUserDB.Model(&SekaiAuditLog{})
+	for param, column := range map[string]string{
+		"user_id":         "user_id",
+		"server":          "server",
+		"route":           "route",
+		"target_user_id":  "target_user_id",
+		"event_id":        "event_id",
+		"serving_account": "serving_account",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if value := c.Query("status"); value != "" {
+		status, err := strconv.Atoi(value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "status must be numeric")
+		}
+		query = query.Where("status = ?", status)
+	}
+	for param, op := range map[string]string{"since": ">=", "until": "<"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		ts, err := parseAuditTime(value)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, param+" must be a unix timestamp or RFC3339 time")
+		}
+		query = query.Where("created_at "+op+" ?", ts)
+	}
+
+	limit, offset := 100, 0
+	for key, dst := range map[string]*int{"limit": &limit, "offset": &offset} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if !digitsRe.MatchString(value) {
+			return fiber.NewError(fiber.StatusBadRequest, key+" must be numeric")
+		}
+		*dst, _ = strconv.Atoi(value)
+	}
+	limit = min(max(limit, 1), maxAuditLogQueryLimit)
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	logs := []SekaiAuditLog{}
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(fiber.Map{"total": total, "logs": logs})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"haruki-sekai-api/config"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAuditLogWriterCloseDrains(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&SekaiAuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newAuditLogWriter(config.AuditLogConfig{BatchSize: 100, FlushInterval: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.db = db
+	go w.run()
+
+	const records = 250
+	for i := range records {
+		w.record(SekaiAuditLog{CreatedAt: time.Now().Unix(), UserID: "alice", Status: 200 + i%2})
+	}
+	w.Close()
+
+	var count int64
+	if err := db.Model(&SekaiAuditLog{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != records {
+		t.Fatalf("wrote %d audit records, want %d", count, records)
+	}
+	w.Close()
+}
+
+func TestRawAPITargetUserIDs(t *testing.T) {
+	route, err := compileRawAPIRoute(config.RawAPIRouteConfig{
+		Path:        "/user/{userId}/friend/{friendUserId}",
+		QueryParams: map[string]string{"targetUserId": `^\d+$`, "page": `^\d+$`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := splitRawAPIPath("/user/{userId}/friend/123")
+	if _, ok := route.match("jp", segments); !ok {
+		t.Fatal("route did not match")
+	}
+	targets := route.targetUserIDs(segments, map[string]any{"targetUserId": "456", "page": "2"})
+	if len(targets) != 2 || targets[0] != "123" || targets[1] != "456" {
+		t.Fatalf("targets = %v, want [123 456]", targets)
+	}
+}
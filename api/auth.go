@@ -0,0 +1,180 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"haruki-sekai-api/config"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	authModeNone         = "none"
+	authModeStaticTokens = "static_tokens"
+	authModeDatabase     = "database"
+	authModeJWTOnly      = "jwt_only"
+)
+
+type staticTokenConfig struct {
+	Token              string   `yaml:"token,omitempty"`
+	TokenSHA256        string   `yaml:"token_sha256,omitempty"`
+	UserID             string   `yaml:"user_id"`
+	Remark             string   `yaml:"remark,omitempty"`
+	Servers            []string `yaml:"servers"`
+	Scopes             []string `yaml:"scopes,omitempty"`
+	RateLimitPerSecond float64  `yaml:"rate_limit_per_second,omitempty"`
+	RateLimitBurst     int      `yaml:"rate_limit_burst,omitempty"`
+	DailyQuota         int64    `yaml:"daily_quota,omitempty"`
+}
+
+type staticTokensFile struct {
+	Tokens []staticTokenConfig `yaml:"tokens"`
+}
+
+type staticToken struct {
+	user    SekaiUser
+	servers []string
+	scopes  []string
+}
+
+var (
+	harukiAuthMode     string
+	harukiStaticTokens map[[sha256.Size]byte]*staticToken
+)
+
+func resolveAuthMode(cfg config.Config, jwtConfigured bool) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(cfg.Auth.Mode))
+	if mode == "" {
+		return "", fmt.Errorf("auth.mode must be set to one of %s, %s, %s or %s", authModeNone, authModeStaticTokens, authModeDatabase, authModeJWTOnly)
+	}
+	if mode != authModeStaticTokens && cfg.Auth.StaticTokensFile != "" {
+		return "", fmt.Errorf("auth.static_tokens_file is set but auth.mode is %s", mode)
+	}
+	switch mode {
+	case authModeNone:
+	case authModeStaticTokens:
+		if cfg.Auth.StaticTokensFile == "" {
+			return "", fmt.Errorf("auth.mode static_tokens requires auth.static_tokens_file")
+		}
+	case authModeDatabase:
+		if !cfg.Gorm.Enabled {
+			return "", fmt.Errorf("auth.mode database requires gorm to be enabled")
+		}
+		if !jwtConfigured {
+			return "", fmt.Errorf("auth.mode database requires a JWT signing key or public keys")
+		}
+	case authModeJWTOnly:
+		if !jwtConfigured {
+			return "", fmt.Errorf("auth.mode jwt_only requires a JWT signing key or public keys")
+		}
+	default:
+		return "", fmt.Errorf("unknown auth.mode %q", cfg.Auth.Mode)
+	}
+	return mode, nil
+}
+
+func loadStaticTokens(path string) (map[[sha256.Size]byte]*staticToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file staticTokensFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	tokens := make(map[[sha256.Size]byte]*staticToken, len(file.Tokens))
+	for i, tc := range file.Tokens {
+		var digest [sha256.Size]byte
+		switch {
+		case tc.Token != "" && tc.TokenSHA256 != "":
+			return nil, fmt.Errorf("static token #%d: set either token or token_sha256", i)
+		case tc.Token != "":
+			digest = sha256.Sum256([]byte(tc.Token))
+		case tc.TokenSHA256 != "":
+			raw, err := hex.DecodeString(tc.TokenSHA256)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("static token #%d: invalid token_sha256", i)
+			}
+			copy(digest[:], raw)
+		default:
+			return nil, fmt.Errorf("static token #%d: token is required", i)
+		}
+		if tc.UserID == "" {
+			return nil, fmt.Errorf("static token #%d: user_id is required", i)
+		}
+		if _, ok := tokens[digest]; ok {
+			return nil, fmt.Errorf("static token #%d: duplicate token", i)
+		}
+		servers, err := parseServerList(tc.Servers)
+		if err != nil {
+			return nil, fmt.Errorf("static token #%d: %w", i, err)
+		}
+		scopes, err := normalizeScopes(tc.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("static token #%d: %w", i, err)
+		}
+		tokens[digest] = &staticToken{
+			user: SekaiUser{
+				ID:                 tc.UserID,
+				Remark:             tc.Remark,
+				RateLimitPerSecond: tc.RateLimitPerSecond,
+				RateLimitBurst:     tc.RateLimitBurst,
+				DailyQuota:         tc.DailyQuota,
+			},
+			servers: servers,
+			scopes:  splitScopes(scopes),
+		}
+	}
+	return tokens, nil
+}
+
+func initAuth(cfg config.Config) error {
+	if err := initAuthKeys(cfg); err != nil {
+		return err
+	}
+	mode, err := resolveAuthMode(cfg, harukiJWTVerifier != nil)
+	if err != nil {
+		return err
+	}
+	if mode == authModeDatabase && HarukiSekaiUserDB == nil {
+		return fmt.Errorf("auth.mode database requires a configured gorm dialect and dsn")
+	}
+	if mode == authModeStaticTokens {
+		tokens, err := loadStaticTokens(cfg.Auth.StaticTokensFile)
+		if err != nil {
+			return fmt.Errorf("load static tokens: %w", err)
+		}
+		harukiStaticTokens = tokens
+	}
+	harukiAuthMode = mode
+	return initRoutePolicies(cfg.Auth.RoutePolicies)
+}
+
+func validateStaticToken(c fiber.Ctx, tokenStr, server string) error {
+	token, ok := harukiStaticTokens[sha256.Sum256([]byte(tokenStr))]
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+	if !slices.Contains(token.servers, server) {
+		return fiber.NewError(fiber.StatusForbidden, "Not authorized for this server")
+	}
+	c.Locals("sekaiUser", token.user)
+	c.Locals("sekaiGrantScopes", token.scopes)
+	return c.Next()
+}
+
+func validateJWTClaimsOnly(c fiber.Ctx, claims jwt.MapClaims, uid, server string) error {
+	servers := stringListClaim(claims, "servers")
+	if !slices.Contains(servers, server) && !slices.Contains(servers, "*") {
+		return fiber.NewError(fiber.StatusForbidden, "Not authorized for this server")
+	}
+	c.Locals("sekaiUser", SekaiUser{ID: uid})
+	return c.Next()
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"haruki-sekai-api/config"
+)
+
+func TestResolveAuthMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     config.Config
+		jwt     bool
+		want    string
+		wantErr bool
+	}{
+		{name: "empty mode with gorm", cfg: config.Config{Gorm: config.GormConfig{Enabled: true}}, jwt: true, wantErr: true},
+		{name: "empty mode without gorm", wantErr: true},
+		{name: "database", cfg: config.Config{Auth: config.AuthConfig{Mode: "Database"}, Gorm: config.GormConfig{Enabled: true}}, jwt: true, want: authModeDatabase},
+		{name: "database without gorm", cfg: config.Config{Auth: config.AuthConfig{Mode: "database"}}, jwt: true, wantErr: true},
+		{name: "none", cfg: config.Config{Auth: config.AuthConfig{Mode: "none"}}, want: authModeNone},
+		{name: "static tokens file without mode", cfg: config.Config{Auth: config.AuthConfig{Mode: "none", StaticTokensFile: "tokens.yaml"}}, wantErr: true},
+		{name: "unknown", cfg: config.Config{Auth: config.AuthConfig{Mode: "open"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, err := resolveAuthMode(tc.cfg, tc.jwt)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("resolveAuthMode = %q, want an error", mode)
+				}
+				return
+			}
+			if err != nil || mode != tc.want {
+				t.Fatalf("resolveAuthMode = %q, %v, want %q", mode, err, tc.want)
+			}
+		})
+	}
+}
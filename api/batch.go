@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"haruki-sekai-api/client"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/lo"
+)
+
+const (
+	maxBatchProfileSize        = 50
+	maxBatchProfileConcurrency = 8
+)
+
+type batchProfileRequest struct {
+	UserIDs []any `json:"user_ids"`
+}
+
+type batchProfileItem struct {
+	UserID string `json:"user_id"`
+	Status int    `json:"status"`
+	Data   any    `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+var batchRequestDecoder = sonic.Config{UseNumber: true}.Froze()
+
+func parseBatchUserIDs(body []byte) ([]string, error) {
+	var req batchProfileRequest
+	if err := batchRequestDecoder.Unmarshal(body, &req); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if len(req.UserIDs) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "user_ids must not be empty")
+	}
+	userIDs := make([]string, 0, len(req.UserIDs))
+	for _, raw := range req.UserIDs {
+		var id string
+		switch v := raw.(type) {
+		case string:
+			id = v
+		case json.Number:
+			id = v.String()
+		}
+		if !digitsRe.MatchString(id) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("user_id must be numeric: %v", raw))
+		}
+		userIDs = append(userIDs, id)
+	}
+	userIDs = lo.Uniq(userIDs)
+	if len(userIDs) > maxBatchProfileSize {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("user_ids must not contain more than %d items", maxBatchProfileSize))
+	}
+	return userIDs, nil
+}
+
+func getUserProfiles(c fiber.Ctx) error {
+	userIDs, err := parseBatchUserIDs(c.Body())
+	if err != nil {
+		return err
+	}
+	c.Locals("auditTargetUserIDs", userIDs)
+	_, mgr, err := getMgr(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := gameAPIContext(c)
+	defer cancel()
+	results := make([]batchProfileItem, len(userIDs))
+	forEachBounded(len(userIDs), min(mgr.ClientCount(), maxBatchProfileConcurrency), func(i int) {
+		path := fmt.Sprintf("/user/{userId}/%s/profile", userIDs[i])
+		data, status, _ := mgr.GetGameAPI(ctx, path, nil)
+		item := batchProfileItem{UserID: userIDs[i], Status: status}
+		if failed, ok := data.(client.HarukiSekaiAPIFailedResponse); ok {
+			item.Error = failed.Message
+		} else if status != fiber.StatusOK {
+			item.Error = fmt.Sprintf("game server returned status %d", status)
+			item.Data = data
+		} else {
+			item.Data = data
+		}
+		results[i] = item
+	})
+	return c.JSON(fiber.Map{"results": results})
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"haruki-sekai-api/config"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/lo"
+)
+
+const defaultResponseCacheMemoryEntries = 1024
+
+type cachedResponse struct {
+	Status    int    `json:"status"`
+	Body      []byte `json:"body"`
+	StoredAt  int64  `json:"storedAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type responseCacheBackend interface {
+	get(ctx context.Context, key string) (*cachedResponse, bool)
+	set(ctx context.Context, key string, resp *cachedResponse, ttl time.Duration)
+}
+
+type redisResponseCache struct {
+	rdb *redis.Client
+}
+
+func (r *redisResponseCache) get(ctx context.Context, key string) (*cachedResponse, bool) {
+	raw, err := r.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	var resp cachedResponse
+	if err := sonic.Unmarshal(raw, &resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (r *redisResponseCache) set(ctx context.Context, key string, resp *cachedResponse, ttl time.Duration) {
+	raw, err := sonic.Marshal(resp)
+	if err != nil {
+		return
+	}
+	_ = r.rdb.Set(ctx, key, raw, ttl).Err()
+}
+
+type memoryResponseCache struct {
+	mu         sync.Mutex
+	entries    map[string]*cachedResponse
+	maxEntries int
+}
+
+func (m *memoryResponseCache) get(_ context.Context, key string) (*cachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().Unix() >= resp.ExpiresAt {
+		delete(m.entries, key)
+		return nil, false
+	}
+	return resp, true
+}
+
+func (m *memoryResponseCache) set(_ context.Context, key string, resp *cachedResponse, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.entries) >= m.maxEntries {
+		now := time.Now().Unix()
+		for k, v := range m.entries {
+			if now >= v.ExpiresAt {
+				delete(m.entries, k)
+			}
+		}
+		for k := range m.entries {
+			if len(m.entries) < m.maxEntries {
+				break
+			}
+			delete(m.entries, k)
+		}
+	}
+	m.entries[key] = resp
+}
+
+type responseCache struct {
+	backend responseCacheBackend
+	ttls    map[string]time.Duration
+	bypass  []string
+}
+
+var harukiResponseCache *responseCache
+
+func initResponseCache(cfg config.ResponseCacheConfig) error {
+	harukiResponseCache = nil
+	if !cfg.Enabled {
+		return nil
+	}
+	ttls := make(map[string]time.Duration, len(cfg.Routes))
+	for route, raw := range cfg.Routes {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid response cache ttl for %s: %w", route, err)
+		}
+		if ttl > 0 {
+			ttls[route] = ttl
+		}
+	}
+
+	var backend responseCacheBackend
+	if HarukiSekaiRedis != nil {
+		backend = &redisResponseCache{rdb: HarukiSekaiRedis}
+	} else {
+		maxEntries := cfg.MaxMemoryEntries
+		if maxEntries <= 0 {
+			maxEntries = defaultResponseCacheMemoryEntries
+		}
+		backend = &memoryResponseCache{entries: make(map[string]*cachedResponse), maxEntries: maxEntries}
+	}
+	harukiResponseCache = &responseCache{backend: backend, ttls: ttls, bypass: cfg.BypassUserIDs}
+	return nil
+}
+
+func responseCacheKey(server, path string, params map[string]any) string {
+	var b strings.Builder
+	b.WriteString("haruki_sekai_api:response:")
+	b.WriteString(server)
+	b.WriteString(":")
+	b.WriteString(path)
+	keys := lo.Keys(params)
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteString("|")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(fmt.Sprintf("%s=%v", k, params[k]))
+	}
+	return b.String()
+}
+
+func (rc *responseCache) ttlFor(c fiber.Ctx) (time.Duration, bool) {
+	ttl, ok := rc.ttls[c.Route().Path]
+	return ttl, ok
+}
+
+func (rc *responseCache) canBypass(c fiber.Ctx) bool {
+	if !strings.Contains(strings.ToLower(c.Get("Cache-Control")), "no-cache") {
+		return false
+	}
+	user, ok := c.Locals("sekaiUser").(SekaiUser)
+	return ok && lo.Contains(rc.bypass, user.ID)
+}
+
+func writeCachedResponse(c fiber.Ctx, resp *cachedResponse, hit bool) error {
+	now := time.Now().Unix()
+	visibility := lo.Ternary(routePolicy(c.Route().Path) == routePolicyPublic, "public", "private")
+	c.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, max(resp.ExpiresAt-now, 0)))
+	c.Set("Age", fmt.Sprintf("%d", max(now-resp.StoredAt, 0)))
+	c.Set("X-Cache", lo.Ternary(hit, "HIT", "MISS"))
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Status(resp.Status).Send(resp.Body)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"haruki-sekai-api/client"
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils/sessionstore"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/gofiber/fiber/v3"
+)
+
+func InitAdminUtils(cfg config.Config) error {
+	if err := initDatabase(cfg); err != nil {
+		return err
+	}
+	if HarukiSekaiUserDB == nil {
+		return fmt.Errorf("gorm is not enabled")
+	}
+	return initAuthKeys(cfg)
+}
+
+func OpenSessionStore(cfg config.Config) (sessionstore.Store, error) {
+	if !cfg.SessionStore.Enabled {
+		return nil, nil
+	}
+	rdb, err := openRedis(cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	store, err := openSessionStore(cfg.SessionStore, rdb)
+	if err != nil {
+		return nil, fmt.Errorf("session_store: %w", err)
+	}
+	return store, nil
+}
+
+func CheckConfig(cfg config.Config) []error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg.Backend.SSL {
+		for _, path := range []string{cfg.Backend.SSLCert, cfg.Backend.SSLKey} {
+			if _, err := os.Stat(path); err != nil {
+				check(fmt.Errorf("backend: ssl file: %w", err))
+			}
+		}
+	}
+	verifier, err := newJWTVerifier(cfg.Backend.SekaiUserJWTSigningKey, cfg.Backend.JWT)
+	if err != nil {
+		check(fmt.Errorf("backend.jwt: %w", err))
+	}
+	if mode, err := resolveAuthMode(cfg, verifier != nil); err != nil {
+		check(fmt.Errorf("auth: %w", err))
+	} else if mode == authModeStaticTokens {
+		if _, err := loadStaticTokens(cfg.Auth.StaticTokensFile); err != nil {
+			check(fmt.Errorf("auth: static tokens: %w", err))
+		}
+	}
+	if cfg.Gorm.Enabled {
+		switch strings.ToLower(cfg.Gorm.Dialect) {
+		case "mysql", "postgres", "postgresql", "sqlite", "sqlite3", "sqlserver", "mssql":
+		default:
+			check(fmt.Errorf("gorm: unsupported dialect %q", cfg.Gorm.Dialect))
+		}
+	}
+	for _, rc := range cfg.RawAPI.Routes {
+		if _, err := compileRawAPIRoute(rc); err != nil {
+			check(fmt.Errorf("raw_api: %w", err))
+		}
+	}
+	if err := initResponseCache(cfg.ResponseCache); err != nil {
+		check(fmt.Errorf("response_cache: %w", err))
+	}
+	if err := initRoutePolicies(cfg.Auth.RoutePolicies); err != nil {
+		check(fmt.Errorf("auth.route_policies: %w", err))
+	} else if err := RegisterRoutes(fiber.New()); err != nil {
+		check(fmt.Errorf("auth.route_policies: %w", err))
+	}
+	if cfg.SessionStore.Enabled {
+		if cfg.SessionStore.TTL != "" {
+			if _, err := time.ParseDuration(cfg.SessionStore.TTL); err != nil {
+				check(fmt.Errorf("session_store: invalid ttl %q: %w", cfg.SessionStore.TTL, err))
+			}
+		}
+		if !cfg.Redis.Enabled && cfg.SessionStore.Path == "" {
+			check(fmt.Errorf("session_store: path is required when redis is disabled"))
+		}
+	}
+	if cfg.AuditLog.Enabled {
+		if !cfg.Gorm.Enabled {
+			check(fmt.Errorf("audit_log: requires gorm to be enabled"))
+		}
+		if _, err := newAuditLogWriter(cfg.AuditLog); err != nil {
+			check(fmt.Errorf("audit_log: %w", err))
+		}
+	}
+
+	sch, err := gocron.NewScheduler()
+	if err != nil {
+		return append(errs, err)
+	}
+	defer func() { _ = sch.Shutdown() }()
+	checkCron := func(name, expr string) {
+		if expr == "" {
+			return
+		}
+		if _, err := sch.NewJob(gocron.CronJob(expr, true), gocron.NewTask(func() {})); err != nil {
+			check(fmt.Errorf("%s: invalid cron %q: %w", name, expr, err))
+		}
+	}
+	checkCron("audit_log.retention_cron", cfg.AuditLog.RetentionCron)
+
+	for server, serverConfig := range cfg.Servers {
+		if !serverConfig.Enabled {
+			continue
+		}
+		prefix := "servers." + string(server)
+		for name, path := range map[string]string{"account_dir": serverConfig.AccountDir, "version_path": serverConfig.VersionPath} {
+			if path == "" {
+				check(fmt.Errorf("%s: %s is required", prefix, name))
+			} else if _, err := os.Stat(path); err != nil {
+				check(fmt.Errorf("%s: %s: %w", prefix, name, err))
+			}
+		}
+		for name, value := range map[string]string{
+			"account_reload_interval": serverConfig.AccountReloadInterval,
+			"client_quarantine":       serverConfig.ClientQuarantine,
+			"login_stagger":           serverConfig.LoginStagger,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(value); err != nil {
+				check(fmt.Errorf("%s: %s: %w", prefix, name, err))
+			}
+		}
+		if serverConfig.APIURL == "" {
+			check(fmt.Errorf("%s: api_url is required", prefix))
+		}
+		if _, err := client.NewSekaiCryptorFromHex(serverConfig.AESKeyHex, serverConfig.AESIVHex); err != nil {
+			check(fmt.Errorf("%s: aes key: %w", prefix, err))
+		}
+		if serverConfig.EnableMasterUpdater {
+			checkCron(prefix+".master_updater_cron", serverConfig.MasterUpdaterCron)
+		}
+		if serverConfig.EnableAppHashUpdater {
+			checkCron(prefix+".app_hash_updater_cron", serverConfig.AppHashUpdaterCron)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	verifiedCredentialTTL     = time.Minute
+	credentialFailureWindow   = 5 * time.Minute
+	maxCredentialFailures     = 10
+	maxCredentialCacheEntries = 4096
+)
+
+type verifiedCredential struct {
+	user      SekaiUser
+	grant     SekaiUserServer
+	expiresAt time.Time
+}
+
+type credentialFailures struct {
+	count   int
+	resetAt time.Time
+}
+
+type credentialCache struct {
+	mu       sync.Mutex
+	verified map[string]verifiedCredential
+	failures map[string]*credentialFailures
+}
+
+var harukiCredentialCache = newCredentialCache()
+
+func newCredentialCache() *credentialCache {
+	return &credentialCache{
+		verified: make(map[string]verifiedCredential),
+		failures: make(map[string]*credentialFailures),
+	}
+}
+
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func credentialCacheKey(token, uid, server string) string {
+	return tokenDigest(token) + ":" + uid + ":" + server
+}
+
+func (cc *credentialCache) get(key string) (*SekaiUser, *SekaiUserServer, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	entry, ok := cc.verified[key]
+	if !ok {
+		return nil, nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(cc.verified, key)
+		return nil, nil, false
+	}
+	return &entry.user, &entry.grant, true
+}
+
+func (cc *credentialCache) put(key string, user *SekaiUser, grant *SekaiUserServer) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	now := time.Now()
+	if len(cc.verified) >= maxCredentialCacheEntries {
+		for k, entry := range cc.verified {
+			if now.After(entry.expiresAt) {
+				delete(cc.verified, k)
+			}
+		}
+		if len(cc.verified) >= maxCredentialCacheEntries {
+			clear(cc.verified)
+		}
+	}
+	cc.verified[key] = verifiedCredential{user: *user, grant: *grant, expiresAt: now.Add(verifiedCredentialTTL)}
+	delete(cc.failures, "uid:"+user.ID)
+}
+
+func (cc *credentialCache) evictUser(uid string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for k, entry := range cc.verified {
+		if entry.user.ID == uid {
+			delete(cc.verified, k)
+		}
+	}
+}
+
+func (cc *credentialCache) throttled(uid, ip string) (time.Duration, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{"uid:" + uid, "ip:" + ip} {
+		f, ok := cc.failures[key]
+		if !ok {
+			continue
+		}
+		if now.After(f.resetAt) {
+			delete(cc.failures, key)
+			continue
+		}
+		if f.count >= maxCredentialFailures {
+			wait = max(wait, f.resetAt.Sub(now))
+		}
+	}
+	return wait, wait > 0
+}
+
+func (cc *credentialCache) recordFailure(uid, ip string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	now := time.Now()
+	if len(cc.failures) >= maxCredentialCacheEntries {
+		for k, f := range cc.failures {
+			if now.After(f.resetAt) {
+				delete(cc.failures, k)
+			}
+		}
+	}
+	for _, key := range []string{"uid:" + uid, "ip:" + ip} {
+		f, ok := cc.failures[key]
+		if !ok || now.After(f.resetAt) {
+			f = &credentialFailures{resetAt: now.Add(credentialFailureWindow)}
+			cc.failures[key] = f
+		}
+		f.count++
+	}
+}
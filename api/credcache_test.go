@@ -0,0 +1,49 @@
+package api
+
+import "testing"
+
+func TestCredentialCacheEvictUser(t *testing.T) {
+	cc := newCredentialCache()
+	key := credentialCacheKey("token", "alice", "jp")
+	cc.put(key, &SekaiUser{ID: "alice"}, &SekaiUserServer{UserID: "alice", Server: "jp"})
+	cc.put(credentialCacheKey("token", "bob", "jp"), &SekaiUser{ID: "bob"}, &SekaiUserServer{UserID: "bob", Server: "jp"})
+
+	if user, _, ok := cc.get(key); !ok || user.ID != "alice" {
+		t.Fatalf("get = %v, %v", user, ok)
+	}
+	if _, _, ok := cc.get(credentialCacheKey("other", "alice", "jp")); ok {
+		t.Fatal("a different token must not hit the cache")
+	}
+	cc.evictUser("alice")
+	if _, _, ok := cc.get(key); ok {
+		t.Fatal("evicted user still cached")
+	}
+	if _, _, ok := cc.get(credentialCacheKey("token", "bob", "jp")); !ok {
+		t.Fatal("other users must stay cached")
+	}
+}
+
+func TestCredentialCacheThrottle(t *testing.T) {
+	cc := newCredentialCache()
+	for range maxCredentialFailures - 1 {
+		cc.recordFailure("alice", "10.0.0.1")
+	}
+	if _, ok := cc.throttled("alice", "10.0.0.2"); ok {
+		t.Fatal("throttled before reaching the limit")
+	}
+	cc.recordFailure("alice", "10.0.0.1")
+	if wait, ok := cc.throttled("alice", "10.0.0.2"); !ok || wait <= 0 {
+		t.Fatal("uid should be throttled from any ip")
+	}
+	if _, ok := cc.throttled("bob", "10.0.0.1"); !ok {
+		t.Fatal("ip should be throttled for any uid")
+	}
+	if _, ok := cc.throttled("bob", "10.0.0.2"); ok {
+		t.Fatal("unrelated uid and ip must not be throttled")
+	}
+
+	cc.put(credentialCacheKey("token", "alice", "jp"), &SekaiUser{ID: "alice"}, &SekaiUserServer{UserID: "alice", Server: "jp"})
+	if _, ok := cc.throttled("alice", "10.0.0.2"); ok {
+		t.Fatal("a successful verification should reset the uid failures")
+	}
+}
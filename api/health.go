@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"haruki-sekai-api/client"
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type dependencyStatus struct {
+	Enabled   bool   `json:"enabled"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+type serverHealth struct {
+	client.SekaiClientManagerStatus
+	Ready bool `json:"ready"`
+}
+
+type serverReadiness struct {
+	Server             utils.HarukiSekaiServerRegion `json:"server"`
+	Ready              bool                          `json:"ready"`
+	UnderMaintenance   bool                          `json:"underMaintenance"`
+	Clients            int                           `json:"clients"`
+	LoggedInClients    int                           `json:"loggedInClients"`
+	HealthyClients     int                           `json:"healthyClients"`
+	QuarantinedClients int                           `json:"quarantinedClients"`
+	PendingAccounts    int                           `json:"pendingAccounts"`
+}
+
+type healthReport struct {
+	Status   string            `json:"status"`
+	Version  string            `json:"version"`
+	Database dependencyStatus  `json:"database"`
+	Redis    dependencyStatus  `json:"redis"`
+	Servers  []serverReadiness `json:"servers"`
+}
+
+type adminHealthReport struct {
+	Status   string           `json:"status"`
+	Version  string           `json:"version"`
+	Database dependencyStatus `json:"database"`
+	Redis    dependencyStatus `json:"redis"`
+	Servers  []serverHealth   `json:"servers"`
+}
+
+func checkDatabase(ctx context.Context) dependencyStatus {
+	if HarukiSekaiUserDB == nil {
+		return dependencyStatus{}
+	}
+	status := dependencyStatus{Enabled: true}
+	sqlDB, err := HarukiSekaiUserDB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	return status
+}
+
+func checkRedis(ctx context.Context) dependencyStatus {
+	if HarukiSekaiRedis == nil {
+		return dependencyStatus{}
+	}
+	status := dependencyStatus{Enabled: true}
+	if err := HarukiSekaiRedis.Ping(ctx).Err(); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	return status
+}
+
+func checkServer(mgr *client.SekaiClientManager) serverHealth {
+	status := mgr.Status()
+	return serverHealth{
+		SekaiClientManagerStatus: status,
+		Ready:                    status.HealthyClients > 0 && !status.UnderMaintenance,
+	}
+}
+
+func (s serverHealth) readiness() serverReadiness {
+	return serverReadiness{
+		Server:             s.Server,
+		Ready:              s.Ready,
+		UnderMaintenance:   s.UnderMaintenance,
+		Clients:            s.Clients,
+		LoggedInClients:    s.LoggedInClients,
+		HealthyClients:     s.HealthyClients,
+		QuarantinedClients: s.QuarantinedClients,
+		PendingAccounts:    len(s.PendingAccounts),
+	}
+}
+
+func (r adminHealthReport) public() healthReport {
+	report := healthReport{
+		Status:   r.Status,
+		Version:  r.Version,
+		Database: dependencyStatus{Enabled: r.Database.Enabled, Reachable: r.Database.Reachable},
+		Redis:    dependencyStatus{Enabled: r.Redis.Enabled, Reachable: r.Redis.Reachable},
+	}
+	for _, server := range r.Servers {
+		report.Servers = append(report.Servers, server.readiness())
+	}
+	return report
+}
+
+func buildHealthReport(c fiber.Ctx) (adminHealthReport, bool) {
+	ctx, cancel := context.WithTimeout(c.RequestCtx(), 2*time.Second)
+	defer cancel()
+
+	report := adminHealthReport{
+		Status:   "ok",
+		Version:  config.Version,
+		Database: checkDatabase(ctx),
+		Redis:    checkRedis(ctx),
+	}
+	ready := (!report.Database.Enabled || report.Database.Reachable) && (!report.Redis.Enabled || report.Redis.Reachable)
+	for _, mgr := range HarukiSekaiManagers {
+		server := checkServer(mgr)
+		ready = ready && server.Ready
+		report.Servers = append(report.Servers, server)
+	}
+	sort.Slice(report.Servers, func(i, j int) bool {
+		return report.Servers[i].Server < report.Servers[j].Server
+	})
+	if !ready {
+		report.Status = "degraded"
+	}
+	return report, ready
+}
+
+func serverHealthFromCtx(c fiber.Ctx) (serverHealth, error) {
+	region, err := utils.ParseSekaiServerRegion(strings.ToLower(c.Params("server")))
+	if err != nil {
+		return serverHealth{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	mgr, ok := HarukiSekaiManagers[region]
+	if !ok || mgr == nil {
+		return serverHealth{}, fiber.NewError(fiber.StatusNotFound, "server not enabled")
+	}
+	return checkServer(mgr), nil
+}
+
+func getHealthz(c fiber.Ctx) error {
+	report, _ := buildHealthReport(c)
+	return c.JSON(report.public())
+}
+
+func getReadyz(c fiber.Ctx) error {
+	report, ready := buildHealthReport(c)
+	if !ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report.public())
+	}
+	return c.JSON(report.public())
+}
+
+func getServerReadyz(c fiber.Ctx) error {
+	server, err := serverHealthFromCtx(c)
+	if err != nil {
+		return err
+	}
+	if !server.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(server.readiness())
+	}
+	return c.JSON(server.readiness())
+}
+
+func adminGetHealth(c fiber.Ctx) error {
+	report, _ := buildHealthReport(c)
+	return c.JSON(report)
+}
+
+func adminGetServerHealth(c fiber.Ctx) error {
+	server, err := serverHealthFromCtx(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(server)
+}
+
+func registerHealthRoutes(app *fiber.App) {
+	app.Get("/healthz", getHealthz)
+	app.Get("/readyz", getReadyz)
+	app.Get("/readyz/:server", getServerReadyz)
+}
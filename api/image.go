@@ -52,7 +52,7 @@ func getMySekaiImage(c fiber.Ctx) error {
 }
 
 func registerHarukiSekaiImageRoutes(app *fiber.App) {
-	image := app.Group("/image/:server", validateUserTokenMiddleware())
+	image := newRouteGroup(app, "/image/:server", true)
 
-	image.Get("/mysekai/:param1/:param2", getMySekaiImage)
+	image.get("/mysekai/:param1/:param2", scopeImage, getMySekaiImage)
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"haruki-sekai-api/client"
 	"haruki-sekai-api/config"
@@ -9,6 +10,7 @@ import (
 	"haruki-sekai-api/utils/apphash"
 	"haruki-sekai-api/utils/git"
 	harukiLogger "haruki-sekai-api/utils/logger"
+	"haruki-sekai-api/utils/sessionstore"
 	"log"
 	"os"
 	"strings"
@@ -32,7 +34,9 @@ var (
 	HarukiSekaiRedis             *redis.Client
 	HarukiSekaiUserDB            *gorm.DB
 	HarukiSekaiUserJWTSigningKey *string
+	HarukiSekaiAdminToken        string
 	harukiSchedulerLogger        *harukiLogger.Logger
+	harukiScheduler              gocron.Scheduler
 )
 
 func gormLoggerFromConfig(lc config.GormLoggerConfig) logger.Interface {
@@ -131,6 +135,27 @@ func openRedis(cfg config.RedisConfig) (*redis.Client, error) {
 	return rdb, nil
 }
 
+func openSessionStore(cfg config.SessionStoreConfig, rdb *redis.Client) (sessionstore.Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	var ttl time.Duration
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl %q: %w", cfg.TTL, err)
+		}
+		ttl = d
+	}
+	if rdb != nil {
+		return sessionstore.NewRedisStore(rdb, "haruki_sekai_api:session:", ttl), nil
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("path is required when redis is disabled")
+	}
+	return sessionstore.NewFileStore(cfg.Path, ttl)
+}
+
 func initDatabase(cfg config.Config) error {
 	db, err := openGorm(cfg.Gorm)
 	if err != nil {
@@ -139,7 +164,7 @@ func initDatabase(cfg config.Config) error {
 	HarukiSekaiUserDB = db
 
 	if HarukiSekaiUserDB != nil {
-		if err := HarukiSekaiUserDB.AutoMigrate(&SekaiUser{}, &SekaiUserServer{}); err != nil {
+		if err := HarukiSekaiUserDB.AutoMigrate(&SekaiUser{}, &SekaiUserServer{}, &SekaiRevokedToken{}, &SekaiAuditLog{}); err != nil {
 			return err
 		}
 	}
@@ -149,15 +174,27 @@ func initDatabase(cfg config.Config) error {
 		return err
 	}
 	HarukiSekaiRedis = rdb
+
+	if HarukiSekaiUserDB != nil && HarukiSekaiRedis != nil {
+		if err := syncRevokedTokensToRedis(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func initSekaiManagers(cfg config.Config, harukiGit *git.HarukiGitUpdater) map[utils.HarukiSekaiServerRegion]*client.SekaiClientManager {
+func initSekaiManagers(cfg config.Config, harukiGit *git.HarukiGitUpdater, sessionStore sessionstore.Store) map[utils.HarukiSekaiServerRegion]*client.SekaiClientManager {
 	sekaiManager := make(map[utils.HarukiSekaiServerRegion]*client.SekaiClientManager)
 	for server, serverConfig := range cfg.Servers {
 		if serverConfig.Enabled {
 			sekaiManager[server] = client.NewSekaiClientManager(server, serverConfig, cfg.AssetUpdaterServers, harukiGit, cfg.Proxy, cfg.JPSekaiCookieURL)
-			_ = sekaiManager[server].Init()
+			sekaiManager[server].SessionStore = sessionStore
+			if err := sekaiManager[server].Init(); err != nil {
+				sekaiManager[server].Logger.Errorf("%s client manager initialization failed: %v", strings.ToUpper(string(server)), err)
+			}
+			if err := sekaiManager[server].WatchAccounts(); err != nil {
+				sekaiManager[server].Logger.Errorf("%s account watcher failed to start: %v", strings.ToUpper(string(server)), err)
+			}
 		}
 	}
 	return sekaiManager
@@ -225,7 +262,29 @@ func InitAPIUtils(cfg config.Config) error {
 		return err
 	}
 
-	sekaiManager := initSekaiManagers(cfg, harukiGit)
+	if err := initAuth(cfg); err != nil {
+		return err
+	}
+
+	if err := initRawAPIRoutes(cfg.RawAPI); err != nil {
+		return err
+	}
+
+	if err := initResponseCache(cfg.ResponseCache); err != nil {
+		return err
+	}
+	initRateLimiter()
+
+	if err := initAuditLog(cfg.AuditLog); err != nil {
+		return err
+	}
+
+	sessionStore, err := openSessionStore(cfg.SessionStore, HarukiSekaiRedis)
+	if err != nil {
+		return fmt.Errorf("session_store: %w", err)
+	}
+
+	sekaiManager := initSekaiManagers(cfg, harukiGit, sessionStore)
 	HarukiSekaiManagers = sekaiManager
 
 	sch, err := gocron.NewScheduler(gocron.WithLocation(time.Local))
@@ -242,10 +301,36 @@ func InitAPIUtils(cfg config.Config) error {
 		return err
 	}
 
+	if err := registerAuditLogRetention(cfg.AuditLog, sch); err != nil {
+		return err
+	}
+
 	sch.Start()
+	harukiScheduler = sch
+	return nil
+}
+
+func ShutdownAPIUtils() error {
+	var errs []error
+	if harukiScheduler != nil {
+		errs = append(errs, harukiScheduler.Shutdown())
+	}
+	for _, mgr := range HarukiSekaiManagers {
+		errs = append(errs, mgr.Shutdown())
+	}
+	if harukiAuditLog != nil {
+		harukiAuditLog.Close()
+	}
+	return errors.Join(errs...)
+}
 
+func initAuthKeys(cfg config.Config) error {
 	if cfg.Backend.SekaiUserJWTSigningKey != "" {
 		HarukiSekaiUserJWTSigningKey = &cfg.Backend.SekaiUserJWTSigningKey
 	}
+	if err := initJWTVerifier(cfg.Backend); err != nil {
+		return err
+	}
+	HarukiSekaiAdminToken = cfg.Backend.AdminToken
 	return nil
 }
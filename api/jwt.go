@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils/jwks"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var asymmetricJWTAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type jwtVerifier struct {
+	secret  []byte
+	keys    *jwks.KeySet
+	options []jwt.ParserOption
+}
+
+var harukiJWTVerifier *jwtVerifier
+
+func loadJWTKeySet(cfg config.JWTConfig) (*jwks.KeySet, error) {
+	keys := jwks.NewKeySet()
+	for _, pk := range cfg.PublicKeys {
+		data, err := os.ReadFile(pk.Path)
+		if err != nil {
+			return nil, fmt.Errorf("read public key %s: %w", pk.Path, err)
+		}
+		key, err := jwks.ParsePEM(pk.KID, data)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key %s: %w", pk.Path, err)
+		}
+		keys.Add(key)
+	}
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwks file: %w", err)
+		}
+		parsed, err := jwks.ParseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse jwks file: %w", err)
+		}
+		keys.Add(parsed...)
+	}
+	return keys, nil
+}
+
+func newJWTVerifier(secret string, cfg config.JWTConfig) (*jwtVerifier, error) {
+	keys, err := loadJWTKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	v := &jwtVerifier{keys: keys}
+	if secret != "" {
+		v.secret = []byte(secret)
+	}
+
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		if v.secret != nil {
+			algorithms = append(algorithms, jwt.SigningMethodHS256.Alg())
+		}
+		if keys.Len() > 0 {
+			algorithms = append(algorithms, asymmetricJWTAlgorithms...)
+		}
+	}
+	for _, alg := range algorithms {
+		if alg == jwt.SigningMethodHS256.Alg() {
+			if v.secret == nil {
+				return nil, fmt.Errorf("HS256 is enabled but sekai_user_jwt_signing_key is empty")
+			}
+			continue
+		}
+		if jwt.GetSigningMethod(alg) == nil || alg == "none" || strings.HasPrefix(alg, "HS") {
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+		if keys.Len() == 0 {
+			return nil, fmt.Errorf("JWT algorithm %s is enabled but no public keys are configured", alg)
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil, nil
+	}
+
+	v.options = append(v.options, jwt.WithValidMethods(algorithms), jwt.WithIssuedAt())
+	if cfg.Audience != "" {
+		v.options = append(v.options, jwt.WithAudience(cfg.Audience))
+	}
+	if cfg.Issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.RequireExp {
+		v.options = append(v.options, jwt.WithExpirationRequired())
+	}
+	if cfg.Leeway != "" {
+		leeway, err := time.ParseDuration(cfg.Leeway)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt leeway: %w", err)
+		}
+		v.options = append(v.options, jwt.WithLeeway(leeway))
+	}
+	return v, nil
+}
+
+func (v *jwtVerifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	alg := t.Method.Alg()
+	if alg == jwt.SigningMethodHS256.Alg() {
+		if v.secret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %s", alg)
+		}
+		return v.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	return v.keys.Lookup(kid, alg)
+}
+
+func (v *jwtVerifier) parse(tokenStr string) (*jwt.Token, jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, v.keyFunc, v.options...)
+	if err != nil || !token.Valid {
+		return nil, nil, err
+	}
+	return token, claims, nil
+}
+
+func initJWTVerifier(cfg config.BackendConfig) error {
+	v, err := newJWTVerifier(cfg.SekaiUserJWTSigningKey, cfg.JWT)
+	if err != nil {
+		return err
+	}
+	harukiJWTVerifier = v
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"haruki-sekai-api/utils/masterdata"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var masterReservedQueries = map[string]bool{"fields": true, "limit": true, "offset": true}
+
+func parseMasterQuery(c fiber.Ctx) (masterdata.Query, error) {
+	var q masterdata.Query
+	for key, value := range c.Queries() {
+		if masterReservedQueries[key] {
+			continue
+		}
+		if q.Filters == nil {
+			q.Filters = make(map[string]string)
+		}
+		q.Filters[key] = value
+	}
+	if fields := c.Query("fields"); fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				q.Fields = append(q.Fields, f)
+			}
+		}
+	}
+	for key, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if !digitsRe.MatchString(value) {
+			return q, fiber.NewError(fiber.StatusBadRequest, key+" must be numeric")
+		}
+		*dst, _ = strconv.Atoi(value)
+	}
+	return q, nil
+}
+
+func getMasterTable(c fiber.Ctx) error {
+	_, mgr, err := getMgr(c)
+	if err != nil {
+		return err
+	}
+	table, err := mgr.MasterStore.Table(c.Params("table"))
+	if err != nil {
+		switch {
+		case errors.Is(err, masterdata.ErrInvalidTable):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, masterdata.ErrTableNotFound):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to load master table")
+		}
+	}
+
+	q, err := parseMasterQuery(c)
+	if err != nil {
+		return err
+	}
+	if !table.IsList() {
+		if len(q.Filters) > 0 || len(q.Fields) > 0 || q.Limit > 0 || q.Offset > 0 {
+			return fiber.NewError(fiber.StatusBadRequest, masterdata.ErrNotQueryable.Error())
+		}
+		return c.JSON(table.Raw())
+	}
+	result, err := table.Query(q)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	c.Set("X-Total-Count", strconv.Itoa(result.Total))
+	return c.JSON(result.Rows)
+}
+
+func registerHarukiSekaiMasterRoutes(app *fiber.App) {
+	master := newRouteGroup(app, "/master/:server", false)
+
+	master.get("/:table", scopeMaster, getMasterTable)
+}
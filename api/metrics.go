@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils/metrics"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func init() {
+	metrics.NewGaugeFunc("haruki_sekai_clients", "Game account clients per server.", func() []metrics.Sample {
+		var samples []metrics.Sample
+		for server, mgr := range HarukiSekaiManagers {
+			status := mgr.Status()
+			samples = append(samples,
+				metrics.Sample{LabelValues: []string{string(server), "total"}, Value: float64(status.Clients)},
+				metrics.Sample{LabelValues: []string{string(server), "logged_in"}, Value: float64(status.LoggedInClients)},
+				metrics.Sample{LabelValues: []string{string(server), "healthy"}, Value: float64(status.HealthyClients)},
+				metrics.Sample{LabelValues: []string{string(server), "quarantined"}, Value: float64(status.QuarantinedClients)},
+			)
+		}
+		return samples
+	}, "server", "state")
+}
+
+func metricsMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		startedAt := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+		route := c.Route().Path
+		metrics.HTTPRequests.Inc(c.Method(), route, strconv.Itoa(status))
+		metrics.HTTPRequestDuration.Observe(time.Since(startedAt).Seconds(), c.Method(), route)
+		return err
+	}
+}
+
+func getMetrics(c fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Default.WriteText(c.Response().BodyWriter())
+	return nil
+}
+
+func registerMetricsRoutes(app *fiber.App) {
+	if !config.Cfg.Backend.EnableMetrics {
+		return
+	}
+	app.Use(metricsMiddleware())
+	app.Get("/metrics", getMetrics)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	routePolicyPublic = "public"
+	routePolicyToken  = "token"
+	routePolicyAdmin  = "admin"
+)
+
+var protectedRoutes = map[string]bool{
+	"/api/:server/:user_id/profile":                      true,
+	"/api/:server/profiles":                              true,
+	"/api/:server/event/:event_id/ranking/user/:user_id": true,
+	"/api/:server/raw/*":                                 true,
+	"/image/:server/mysekai/:param1/:param2":             true,
+}
+
+var (
+	harukiRoutePolicies    map[string]string
+	harukiRegisteredRoutes = make(map[string]bool)
+	harukiUpstreamRoutes   = make(map[string]bool)
+)
+
+func initRoutePolicies(policies map[string]string) error {
+	validated := make(map[string]string, len(policies))
+	for route, policy := range policies {
+		policy = strings.ToLower(strings.TrimSpace(policy))
+		switch policy {
+		case routePolicyPublic, routePolicyToken, routePolicyAdmin:
+		default:
+			return fmt.Errorf("invalid policy %q for route %s", policy, route)
+		}
+		if policy == routePolicyPublic && protectedRoutes[route] {
+			return fmt.Errorf("route %s serves player data and cannot be public", route)
+		}
+		validated[route] = policy
+	}
+	harukiRoutePolicies = validated
+	return nil
+}
+
+func routePolicy(route string) string {
+	if policy, ok := harukiRoutePolicies[route]; ok {
+		return policy
+	}
+	return routePolicyToken
+}
+
+func checkRoutePolicies() error {
+	var unknown []string
+	for route := range harukiRoutePolicies {
+		if !harukiRegisteredRoutes[route] {
+			unknown = append(unknown, route)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("route policies reference unknown routes: %s", strings.Join(unknown, ", "))
+	}
+	var uncached []string
+	for route, policy := range harukiRoutePolicies {
+		if policy != routePolicyPublic || !harukiUpstreamRoutes[route] {
+			continue
+		}
+		if harukiResponseCache == nil || harukiResponseCache.ttls[route] <= 0 {
+			uncached = append(uncached, route)
+		}
+	}
+	if len(uncached) > 0 {
+		sort.Strings(uncached)
+		return fmt.Errorf("public routes call the game server and need a response_cache ttl: %s", strings.Join(uncached, ", "))
+	}
+	return nil
+}
+
+type routeGroup struct {
+	router   fiber.Router
+	prefix   string
+	upstream bool
+}
+
+func newRouteGroup(app *fiber.App, prefix string, upstream bool) *routeGroup {
+	return &routeGroup{router: app.Group(prefix), prefix: prefix, upstream: upstream}
+}
+
+func (g *routeGroup) handle(method, path, scope string, handler fiber.Handler) {
+	route := g.prefix + path
+	harukiRegisteredRoutes[route] = true
+	if g.upstream {
+		harukiUpstreamRoutes[route] = true
+	}
+
+	var chain []fiber.Handler
+	switch routePolicy(route) {
+	case routePolicyPublic:
+		chain = []fiber.Handler{handler}
+	case routePolicyAdmin:
+		chain = []fiber.Handler{adminTokenMiddleware(), handler}
+	default:
+		chain = []fiber.Handler{validateUserTokenMiddleware(), auditLogMiddleware(), requireScope(scope), rateLimitMiddleware(), handler}
+	}
+	g.router.Add([]string{method}, path, chain[0], chain[1:]...)
+}
+
+func (g *routeGroup) get(path, scope string, handler fiber.Handler) {
+	g.handle(fiber.MethodGet, path, scope, handler)
+}
+
+func (g *routeGroup) post(path, scope string, handler fiber.Handler) {
+	g.handle(fiber.MethodPost, path, scope, handler)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func resetRoutePolicies(t *testing.T) {
+	t.Helper()
+	policies, registered, upstream, cache := harukiRoutePolicies, harukiRegisteredRoutes, harukiUpstreamRoutes, harukiResponseCache
+	harukiRoutePolicies = nil
+	harukiRegisteredRoutes = make(map[string]bool)
+	harukiUpstreamRoutes = make(map[string]bool)
+	harukiResponseCache = nil
+	t.Cleanup(func() {
+		harukiRoutePolicies, harukiRegisteredRoutes, harukiUpstreamRoutes, harukiResponseCache = policies, registered, upstream, cache
+	})
+}
+
+func registerTestRoutes() {
+	app := fiber.New()
+	handler := func(c fiber.Ctx) error { return nil }
+	newRouteGroup(app, "/api/:server", true).get("/system", scopeInformation, handler)
+	newRouteGroup(app, "/master/:server", false).get("/:name", scopeMaster, handler)
+}
+
+func TestInitRoutePolicies(t *testing.T) {
+	resetRoutePolicies(t)
+
+	if err := initRoutePolicies(map[string]string{"/api/:server/system": "open"}); err == nil {
+		t.Fatal("expected an error for an invalid policy")
+	}
+	if err := initRoutePolicies(map[string]string{"/api/:server/profiles": "public"}); err == nil {
+		t.Fatal("expected an error for a public player data route")
+	}
+	if err := initRoutePolicies(map[string]string{"/api/:server/system": " Public ", "/admin/stats": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"/api/:server/system":   routePolicyPublic,
+		"/admin/stats":          routePolicyAdmin,
+		"/api/:server/profiles": routePolicyToken,
+	}
+	for route, want := range cases {
+		if got := routePolicy(route); got != want {
+			t.Errorf("routePolicy(%s) = %s, want %s", route, got, want)
+		}
+	}
+}
+
+func TestCheckRoutePolicies(t *testing.T) {
+	cases := []struct {
+		name     string
+		policies map[string]string
+		ttls     map[string]time.Duration
+		wantErr  string
+	}{
+		{name: "default policies"},
+		{
+			name:     "unknown route",
+			policies: map[string]string{"/api/:server/missing": "token"},
+			wantErr:  "unknown routes: /api/:server/missing",
+		},
+		{
+			name:     "public upstream route without cache",
+			policies: map[string]string{"/api/:server/system": "public"},
+			wantErr:  "need a response_cache ttl: /api/:server/system",
+		},
+		{
+			name:     "public upstream route without ttl",
+			policies: map[string]string{"/api/:server/system": "public"},
+			ttls:     map[string]time.Duration{"/master/:server/:name": time.Minute},
+			wantErr:  "need a response_cache ttl: /api/:server/system",
+		},
+		{
+			name:     "public upstream route with ttl",
+			policies: map[string]string{"/api/:server/system": "public"},
+			ttls:     map[string]time.Duration{"/api/:server/system": time.Minute},
+		},
+		{
+			name:     "public local route",
+			policies: map[string]string{"/master/:server/:name": "public"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetRoutePolicies(t)
+			if err := initRoutePolicies(tc.policies); err != nil {
+				t.Fatal(err)
+			}
+			if tc.ttls != nil {
+				harukiResponseCache = &responseCache{ttls: tc.ttls}
+			}
+			registerTestRoutes()
+
+			err := checkRoutePolicies()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local need = math.min(cost, burst)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= need then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((need - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens), wait}
+`)
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  float64
+	retryAfter time.Duration
+}
+
+type rateLimiter interface {
+	take(ctx context.Context, uid string, rate float64, burst int, cost int) (rateLimitResult, error)
+	consumeQuota(ctx context.Context, uid string, day string, cost int) (int64, error)
+}
+
+type redisRateLimiter struct {
+	rdb *redis.Client
+}
+
+func (r *redisRateLimiter) take(ctx context.Context, uid string, rate float64, burst int, cost int) (rateLimitResult, error) {
+	key := fmt.Sprintf("haruki_sekai_api:ratelimit:%s", uid)
+	res, err := tokenBucketScript.Run(ctx, r.rdb, []string{key}, rate, burst, time.Now().UnixMilli(), cost).Slice()
+	if err != nil || len(res) != 3 {
+		return rateLimitResult{allowed: true}, err
+	}
+	allowed, _ := res[0].(int64)
+	remainingStr, _ := res[1].(string)
+	remaining, _ := strconv.ParseFloat(remainingStr, 64)
+	wait, _ := res[2].(int64)
+	return rateLimitResult{allowed: allowed == 1, remaining: remaining, retryAfter: time.Duration(wait) * time.Millisecond}, nil
+}
+
+func (r *redisRateLimiter) consumeQuota(ctx context.Context, uid string, day string, cost int) (int64, error) {
+	key := fmt.Sprintf("haruki_sekai_api:quota:%s:%s", uid, day)
+	count, err := r.rdb.IncrBy(ctx, key, int64(cost)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == int64(cost) {
+		_ = r.rdb.Expire(ctx, key, 48*time.Hour).Err()
+	}
+	return count, nil
+}
+
+type memoryBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	quotas  map[string]int64
+	day     string
+}
+
+func (m *memoryRateLimiter) take(_ context.Context, uid string, rate float64, burst int, cost int) (rateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	b, ok := m.buckets[uid]
+	if !ok {
+		b = &memoryBucket{tokens: float64(burst), ts: now}
+		m.buckets[uid] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.ts).Seconds()*rate)
+	b.ts = now
+	need := math.Min(float64(cost), float64(burst))
+	if b.tokens >= need {
+		b.tokens -= float64(cost)
+		return rateLimitResult{allowed: true, remaining: b.tokens}, nil
+	}
+	wait := time.Duration(math.Ceil((need-b.tokens)/rate*1000)) * time.Millisecond
+	return rateLimitResult{remaining: b.tokens, retryAfter: wait}, nil
+}
+
+func (m *memoryRateLimiter) consumeQuota(_ context.Context, uid string, day string, cost int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.day != day {
+		m.day = day
+		m.quotas = make(map[string]int64)
+	}
+	m.quotas[uid] += int64(cost)
+	return m.quotas[uid], nil
+}
+
+var harukiRateLimiter rateLimiter
+
+func initRateLimiter() {
+	if HarukiSekaiRedis != nil {
+		harukiRateLimiter = &redisRateLimiter{rdb: HarukiSekaiRedis}
+		return
+	}
+	harukiRateLimiter = &memoryRateLimiter{buckets: make(map[string]*memoryBucket), quotas: make(map[string]int64)}
+}
+
+var routeRequestCosts = map[string]func(c fiber.Ctx) int{
+	"/api/:server/profiles": func(c fiber.Ctx) int {
+		userIDs, err := parseBatchUserIDs(c.Body())
+		if err != nil {
+			return 1
+		}
+		return len(userIDs)
+	},
+	"/api/:server/event/:event_id/ranking-range": func(c fiber.Ctx) int {
+		ranks, err := parseEventRankingRange(c)
+		if err != nil {
+			return 1
+		}
+		return len(ranks)
+	},
+}
+
+func requestCost(c fiber.Ctx) int {
+	if cost, ok := routeRequestCosts[c.Route().Path]; ok {
+		return max(cost(c), 1)
+	}
+	return 1
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(max(int(math.Ceil(d.Seconds())), 1))
+}
+
+func rateLimitMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		user, ok := c.Locals("sekaiUser").(SekaiUser)
+		if !ok || harukiRateLimiter == nil {
+			return c.Next()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		cost := requestCost(c)
+
+		if user.RateLimitPerSecond > 0 {
+			burst := user.RateLimitBurst
+			if burst <= 0 {
+				burst = max(int(math.Ceil(user.RateLimitPerSecond)), 1)
+			}
+			res, err := harukiRateLimiter.take(ctx, user.ID, user.RateLimitPerSecond, burst, cost)
+			if err == nil {
+				c.Set("X-RateLimit-Limit", strconv.FormatFloat(user.RateLimitPerSecond, 'f', -1, 64))
+				c.Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Max(res.remaining, 0))))
+				if !res.allowed {
+					c.Set("X-RateLimit-Reset", retryAfterSeconds(res.retryAfter))
+					c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(res.retryAfter))
+					return fiber.NewError(fiber.StatusTooManyRequests, "Rate limit exceeded")
+				}
+			}
+		}
+
+		if user.DailyQuota > 0 {
+			now := time.Now().UTC()
+			count, err := harukiRateLimiter.consumeQuota(ctx, user.ID, now.Format("20060102"), cost)
+			if err == nil {
+				c.Set("X-RateLimit-Daily-Limit", strconv.FormatInt(user.DailyQuota, 10))
+				c.Set("X-RateLimit-Daily-Remaining", strconv.FormatInt(max(user.DailyQuota-count, 0), 10))
+				if count > user.DailyQuota {
+					untilReset := now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+					c.Set("X-RateLimit-Reset", retryAfterSeconds(untilReset))
+					c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(untilReset))
+					return fiber.NewError(fiber.StatusTooManyRequests, "Daily quota exceeded")
+				}
+			}
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func newRateLimitTestApp(t *testing.T, user SekaiUser) *fiber.App {
+	t.Helper()
+	limiter := harukiRateLimiter
+	harukiRateLimiter = &memoryRateLimiter{buckets: make(map[string]*memoryBucket), quotas: make(map[string]int64)}
+	t.Cleanup(func() { harukiRateLimiter = limiter })
+
+	app := fiber.New()
+	setUser := func(c fiber.Ctx) error {
+		c.Locals("sekaiUser", user)
+		return c.Next()
+	}
+	ok := func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/api/:server/profiles", setUser, rateLimitMiddleware(), ok)
+	app.Get("/api/:server/event/:event_id/ranking-range", setUser, rateLimitMiddleware(), ok)
+	app.Get("/api/:server/system", setUser, rateLimitMiddleware(), ok)
+	return app
+}
+
+func rateLimitTestRequest(t *testing.T, app *fiber.App, req *http.Request) *http.Response {
+	t.Helper()
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func profilesRequest(userIDs string) *http.Request {
+	req := httptest.NewRequest(fiber.MethodPost, "/api/jp/profiles", strings.NewReader(`{"user_ids":[`+userIDs+`]}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return req
+}
+
+func TestRateLimitBatchConsumesDailyQuota(t *testing.T) {
+	app := newRateLimitTestApp(t, SekaiUser{ID: "batch", DailyQuota: 5})
+
+	resp := rateLimitTestRequest(t, app, profilesRequest(`"1","2","3","4","5"`))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("batch status = %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-RateLimit-Daily-Remaining"); got != "0" {
+		t.Fatalf("daily remaining = %s, want 0", got)
+	}
+	resp = rateLimitTestRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jp/system", nil))
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("status after batch = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestRateLimitBatchConsumesTokens(t *testing.T) {
+	app := newRateLimitTestApp(t, SekaiUser{ID: "range", RateLimitPerSecond: 0.01, RateLimitBurst: 10})
+
+	resp := rateLimitTestRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jp/event/1/ranking-range?start=1&end=10", nil))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("range status = %d", resp.StatusCode)
+	}
+	resp = rateLimitTestRequest(t, app, httptest.NewRequest(fiber.MethodGet, "/api/jp/system", nil))
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("status after range = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestRateLimitBatchLargerThanBurst(t *testing.T) {
+	app := newRateLimitTestApp(t, SekaiUser{ID: "large", RateLimitPerSecond: 0.01, RateLimitBurst: 2})
+
+	resp := rateLimitTestRequest(t, app, profilesRequest(`1,2,3,4`))
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("batch status = %d", resp.StatusCode)
+	}
+	resp = rateLimitTestRequest(t, app, profilesRequest(`1`))
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("status after batch = %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "300" {
+		t.Fatalf("retry after = %s, want 300", got)
+	}
+}
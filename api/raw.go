@@ -0,0 +1,195 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/lo"
+)
+
+const (
+	rawAPIUserIDPlaceholder   = "userId"
+	defaultRawAPIParamPattern = `^[A-Za-z0-9_-]+$`
+)
+
+var rawAPIPlaceholderRe = regexp.MustCompile(`^\{(\w+)}$`)
+
+type rawAPISegment struct {
+	literal string
+	name    string
+	re      *regexp.Regexp
+}
+
+type rawAPIRoute struct {
+	template string
+	servers  []utils.HarukiSekaiServerRegion
+	segments []rawAPISegment
+	query    map[string]*regexp.Regexp
+}
+
+var harukiRawAPIRoutes []*rawAPIRoute
+
+func splitRawAPIPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func compileRawAPIRoute(rc config.RawAPIRouteConfig) (*rawAPIRoute, error) {
+	if !strings.HasPrefix(rc.Path, "/") || strings.ContainsAny(rc.Path, "?#") {
+		return nil, fmt.Errorf("invalid raw api path template: %q", rc.Path)
+	}
+	route := &rawAPIRoute{
+		template: rc.Path,
+		servers:  rc.Servers,
+		query:    make(map[string]*regexp.Regexp, len(rc.QueryParams)),
+	}
+	for _, seg := range splitRawAPIPath(rc.Path) {
+		m := rawAPIPlaceholderRe.FindStringSubmatch(seg)
+		if m == nil {
+			if strings.ContainsAny(seg, "{}") {
+				return nil, fmt.Errorf("invalid segment %q in raw api path template %q", seg, rc.Path)
+			}
+			route.segments = append(route.segments, rawAPISegment{literal: seg})
+			continue
+		}
+		name := m[1]
+		if name == rawAPIUserIDPlaceholder {
+			route.segments = append(route.segments, rawAPISegment{literal: seg})
+			continue
+		}
+		pattern := rc.Params[name]
+		if pattern == "" {
+			pattern = defaultRawAPIParamPattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator for {%s} in %q: %w", name, rc.Path, err)
+		}
+		route.segments = append(route.segments, rawAPISegment{name: name, re: re})
+	}
+	for key, pattern := range rc.QueryParams {
+		if pattern == "" {
+			route.query[key] = nil
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator for query %s in %q: %w", key, rc.Path, err)
+		}
+		route.query[key] = re
+	}
+	return route, nil
+}
+
+func (r *rawAPIRoute) match(region utils.HarukiSekaiServerRegion, segments []string) (string, bool) {
+	if len(r.servers) > 0 && !lo.Contains(r.servers, region) {
+		return "", false
+	}
+	if len(segments) != len(r.segments) {
+		return "", false
+	}
+	parts := make([]string, len(segments))
+	for i, seg := range r.segments {
+		if seg.re == nil {
+			if segments[i] != seg.literal {
+				return "", false
+			}
+			parts[i] = seg.literal
+			continue
+		}
+		if !seg.re.MatchString(segments[i]) || strings.ContainsAny(segments[i], "{}/") {
+			return "", false
+		}
+		parts[i] = segments[i]
+	}
+	return "/" + strings.Join(parts, "/"), true
+}
+
+func (r *rawAPIRoute) queryParams(c fiber.Ctx) (map[string]any, error) {
+	queries := c.Queries()
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	params := make(map[string]any, len(queries))
+	for key, value := range queries {
+		re, ok := r.query[key]
+		if !ok {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("query parameter %s is not allowed", key))
+		}
+		if re != nil && !re.MatchString(value) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid value for query parameter %s", key))
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+func isRawAPITargetUserParam(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "userid")
+}
+
+func (r *rawAPIRoute) targetUserIDs(segments []string, params map[string]any) []string {
+	var targets []string
+	for i, seg := range r.segments {
+		if seg.re != nil && isRawAPITargetUserParam(seg.name) {
+			targets = append(targets, segments[i])
+		}
+	}
+	for key, value := range params {
+		if id, ok := value.(string); ok && isRawAPITargetUserParam(key) {
+			targets = append(targets, id)
+		}
+	}
+	return lo.Uniq(targets)
+}
+
+func initRawAPIRoutes(cfg config.RawAPIConfig) error {
+	harukiRawAPIRoutes = nil
+	if !cfg.Enabled {
+		return nil
+	}
+	for _, rc := range cfg.Routes {
+		route, err := compileRawAPIRoute(rc)
+		if err != nil {
+			return err
+		}
+		harukiRawAPIRoutes = append(harukiRawAPIRoutes, route)
+	}
+	return nil
+}
+
+func getRawGameAPI(c fiber.Ctx) error {
+	region, err := utils.ParseSekaiServerRegion(strings.ToLower(c.Params("server")))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	rawPath, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid path")
+	}
+	segments := splitRawAPIPath(rawPath)
+	for _, route := range harukiRawAPIRoutes {
+		path, ok := route.match(region, segments)
+		if !ok {
+			continue
+		}
+		params, err := route.queryParams(c)
+		if err != nil {
+			return err
+		}
+		if targets := route.targetUserIDs(segments, params); len(targets) > 0 {
+			c.Locals("auditTargetUserIDs", targets)
+		}
+		return proxyGameAPI(c, path, params)
+	}
+	return fiber.NewError(fiber.StatusNotFound, "path is not allowed")
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm/clause"
+)
+
+type adminRevokeTokenRequest struct {
+	Token string `json:"token"`
+	JTI   string `json:"jti"`
+}
+
+func revokedTokenRedisKey(jti string) string {
+	return fmt.Sprintf("haruki_sekai_api:revoked_token:%s", jti)
+}
+
+func tokensValidAfter(now time.Time) int64 {
+	return now.Unix() + 1
+}
+
+func tokenIssuedAfter(issuedAt *jwt.NumericDate, validAfter int64) bool {
+	if validAfter <= 0 {
+		return true
+	}
+	return issuedAt != nil && issuedAt.Unix() >= validAfter
+}
+
+func isTokenRevoked(jti string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if HarukiSekaiRedis != nil {
+		n, err := HarukiSekaiRedis.Exists(ctx, revokedTokenRedisKey(jti)).Result()
+		if err == nil {
+			return n > 0
+		}
+	}
+	if HarukiSekaiUserDB == nil {
+		return false
+	}
+	var count int64
+	if err := HarukiSekaiUserDB.WithContext(ctx).Model(&SekaiRevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+func cacheRevokedToken(ctx context.Context, token SekaiRevokedToken) {
+	if HarukiSekaiRedis == nil {
+		return
+	}
+	var ttl time.Duration
+	if token.ExpiresAt > 0 {
+		ttl = time.Until(time.Unix(token.ExpiresAt, 0))
+		if ttl <= 0 {
+			return
+		}
+	}
+	_ = HarukiSekaiRedis.Set(ctx, revokedTokenRedisKey(token.JTI), token.UserID, ttl).Err()
+}
+
+func syncRevokedTokensToRedis() error {
+	var tokens []SekaiRevokedToken
+	now := time.Now().Unix()
+	if err := HarukiSekaiUserDB.Where("expires_at = 0 OR expires_at > ?", now).Find(&tokens).Error; err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, token := range tokens {
+		cacheRevokedToken(ctx, token)
+	}
+	return nil
+}
+
+func revokeToken(jti, uid string, expiresAt int64) error {
+	token := SekaiRevokedToken{JTI: jti, UserID: uid, ExpiresAt: expiresAt, RevokedAt: time.Now().Unix()}
+	if err := HarukiSekaiUserDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&token).Error; err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cacheRevokedToken(ctx, token)
+	if uid != "" {
+		evictUserCache(uid)
+	}
+	return nil
+}
+
+func revokeUserTokens(uid string) error {
+	if err := HarukiSekaiUserDB.Model(&SekaiUser{}).Where("id = ?", uid).Update("tokens_valid_after", tokensValidAfter(time.Now())).Error; err != nil {
+		return err
+	}
+	evictUserCache(uid)
+	return nil
+}
+
+func adminRevokeUserTokens(c fiber.Ctx) error {
+	uid := c.Params("user_id")
+	if _, err := findSekaiUser(uid); err != nil {
+		return err
+	}
+	if err := revokeUserTokens(uid); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return adminGetUser(c)
+}
+
+func adminRevokeToken(c fiber.Ctx) error {
+	var req adminRevokeTokenRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	jti, uid := req.JTI, ""
+	var expiresAt int64
+	if req.Token != "" {
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(req.Token, claims); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid token")
+		}
+		jti, _ = claims["jti"].(string)
+		uid, _ = claims["uid"].(string)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			expiresAt = exp.Unix()
+		}
+	}
+	if jti == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "token has no jti, revoke all tokens of the user instead")
+	}
+	if err := revokeToken(jti, uid, expiresAt); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(fiber.Map{"jti": jti, "revoked": true})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenIssuedInRevokeSecondIsRejected(t *testing.T) {
+	now := time.Now()
+	validAfter := tokensValidAfter(now)
+	if tokenIssuedAfter(jwt.NewNumericDate(now), validAfter) {
+		t.Fatal("a token issued in the same second as the revoke must be rejected")
+	}
+	if tokenIssuedAfter(jwt.NewNumericDate(now.Add(-time.Second)), validAfter) {
+		t.Fatal("a token issued before the revoke must be rejected")
+	}
+	if !tokenIssuedAfter(jwt.NewNumericDate(now.Add(time.Second)), validAfter) {
+		t.Fatal("a token issued after the revoke must be accepted")
+	}
+	if !tokenIssuedAfter(nil, 0) {
+		t.Fatal("tokens must be accepted when nothing was revoked")
+	}
+}
+
+func TestIssueTokenAfterRevokeIsAccepted(t *testing.T) {
+	key := HarukiSekaiUserJWTSigningKey
+	secret := "test-secret"
+	HarukiSekaiUserJWTSigningKey = &secret
+	t.Cleanup(func() { HarukiSekaiUserJWTSigningKey = key })
+
+	user := &SekaiUser{ID: "alice", TokensValidAfter: tokensValidAfter(time.Now())}
+	token, err := issueSekaiUserToken(user, "credential", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatal(err)
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokenIssuedAfter(issuedAt, user.TokensValidAfter) {
+		t.Fatalf("token issued at %d is rejected by cutoff %d", issuedAt.Unix(), user.TokensValidAfter)
+	}
+}
@@ -2,7 +2,13 @@ package api
 
 import "github.com/gofiber/fiber/v3"
 
-func RegisterRoutes(app *fiber.App) {
+func RegisterRoutes(app *fiber.App) error {
+	registerMetricsRoutes(app)
+	registerHealthRoutes(app)
 	registerHarukiSekaiAPIRoutes(app)
 	registerHarukiSekaiImageRoutes(app)
+	registerHarukiSekaiMasterRoutes(app)
+	registerHarukiSekaiVersionRoutes(app)
+	registerHarukiSekaiAdminRoutes(app)
+	return checkRoutePolicies()
 }
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	scopeProfile     = "profile"
+	scopeRanking     = "ranking"
+	scopeInformation = "information"
+	scopeImage       = "image"
+	scopeMaster      = "master"
+	scopeRaw         = "raw"
+)
+
+var knownScopes = map[string]bool{
+	scopeProfile:     true,
+	scopeRanking:     true,
+	scopeInformation: true,
+	scopeImage:       true,
+	scopeMaster:      true,
+	scopeRaw:         true,
+}
+
+func splitScopes(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+func normalizeScopes(scopes []string) (string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !knownScopes[s] {
+			return "", fiber.NewError(fiber.StatusBadRequest, "unknown scope: "+s)
+		}
+		seen[s] = true
+		normalized = append(normalized, s)
+	}
+	sort.Strings(normalized)
+	return strings.Join(normalized, ","), nil
+}
+
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	return stringListClaim(claims, "scopes")
+}
+
+func stringListClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		if values := splitScopes(v); values != nil {
+			return values
+		}
+		return []string{}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func scopeAllowed(scopes []string, scope string) bool {
+	if scopes == nil {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func requireScope(scope string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		grantScopes, _ := c.Locals("sekaiGrantScopes").([]string)
+		tokenScopes, _ := c.Locals("sekaiTokenScopes").([]string)
+		if !scopeAllowed(grantScopes, scope) || !scopeAllowed(tokenScopes, scope) {
+			return fiber.NewError(fiber.StatusForbidden, "Token is not authorized for scope: "+scope)
+		}
+		return c.Next()
+	}
+}
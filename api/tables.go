@@ -1,9 +1,13 @@
 package api
 
 type SekaiUser struct {
-	ID         string `gorm:"column:id;type:varchar(64);primaryKey"`
-	Credential string `gorm:"column:credential;type:varchar(128);not null"`
-	Remark     string `gorm:"column:remark;type:varchar(255)"`
+	ID                 string  `gorm:"column:id;type:varchar(64);primaryKey"`
+	Credential         string  `gorm:"column:credential;type:varchar(128);not null"`
+	Remark             string  `gorm:"column:remark;type:varchar(255)"`
+	RateLimitPerSecond float64 `gorm:"column:rate_limit_per_second;not null;default:0"`
+	RateLimitBurst     int     `gorm:"column:rate_limit_burst;not null;default:0"`
+	DailyQuota         int64   `gorm:"column:daily_quota;not null;default:0"`
+	TokensValidAfter   int64   `gorm:"column:tokens_valid_after;not null;default:0"`
 }
 
 func (SekaiUser) TableName() string {
@@ -13,8 +17,41 @@ func (SekaiUser) TableName() string {
 type SekaiUserServer struct {
 	UserID string `gorm:"column:user_id;type:varchar(64);primaryKey"`
 	Server string `gorm:"column:server;type:varchar(10);primaryKey"`
+	Scopes string `gorm:"column:scopes;type:varchar(255);not null;default:''"`
 }
 
 func (SekaiUserServer) TableName() string {
 	return "sekai_user_servers"
 }
+
+type SekaiRevokedToken struct {
+	JTI       string `gorm:"column:jti;type:varchar(64);primaryKey"`
+	UserID    string `gorm:"column:user_id;type:varchar(64);index"`
+	ExpiresAt int64  `gorm:"column:expires_at;not null;default:0"`
+	RevokedAt int64  `gorm:"column:revoked_at;not null"`
+}
+
+func (SekaiRevokedToken) TableName() string {
+	return "sekai_revoked_tokens"
+}
+
+type SekaiAuditLog struct {
+	ID             uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	CreatedAt      int64  `gorm:"column:created_at;not null;index" json:"created_at"`
+	UserID         string `gorm:"column:user_id;type:varchar(64);not null;index" json:"user_id"`
+	Server         string `gorm:"column:server;type:varchar(10);not null" json:"server"`
+	Method         string `gorm:"column:method;type:varchar(10);not null" json:"method"`
+	Route          string `gorm:"column:route;type:varchar(255);not null" json:"route"`
+	Path           string `gorm:"column:path;type:varchar(512);not null" json:"path"`
+	TargetUserID   string `gorm:"column:target_user_id;type:varchar(64);index" json:"target_user_id,omitempty"`
+	EventID        string `gorm:"column:event_id;type:varchar(32)" json:"event_id,omitempty"`
+	Status         int    `gorm:"column:status;not null" json:"status"`
+	LatencyMs      int64  `gorm:"column:latency_ms;not null" json:"latency_ms"`
+	ServingAccount string `gorm:"column:serving_account;type:varchar(255)" json:"serving_account,omitempty"`
+	Coalesced      bool   `gorm:"column:coalesced;not null;default:false" json:"coalesced"`
+	ClientIP       string `gorm:"column:client_ip;type:varchar(64)" json:"client_ip"`
+}
+
+func (SekaiAuditLog) TableName() string {
+	return "sekai_audit_logs"
+}
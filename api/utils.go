@@ -2,13 +2,16 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"haruki-sekai-api/utils"
+	harukiCredential "haruki-sekai-api/utils/credential"
 
+	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
@@ -23,75 +26,145 @@ func resolveServerFromCtx(c fiber.Ctx) (utils.HarukiSekaiServerRegion, error) {
 }
 
 func parseJWTToken(tokenStr string) (*jwt.Token, jwt.MapClaims, error) {
-	if HarukiSekaiUserJWTSigningKey == nil || *HarukiSekaiUserJWTSigningKey == "" {
-		return nil, nil, fmt.Errorf("JWT secret not configured")
+	if harukiJWTVerifier == nil {
+		return nil, nil, fmt.Errorf("JWT verification not configured")
 	}
 
-	claims := jwt.MapClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+	token, claims, err := harukiJWTVerifier.parse(tokenStr)
+	if err != nil || token == nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, nil, fmt.Errorf("token expired")
+		case errors.Is(err, jwt.ErrTokenNotValidYet):
+			return nil, nil, fmt.Errorf("token not valid yet")
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, nil, fmt.Errorf("invalid token audience")
 		}
-		return []byte(*HarukiSekaiUserJWTSigningKey), nil
-	})
-	if err != nil || !token.Valid {
 		return nil, nil, fmt.Errorf("invalid token")
 	}
 
 	return token, claims, nil
 }
 
-func checkRedisCache(uid, server string) bool {
+type cachedSekaiUser struct {
+	Remark             string  `json:"remark"`
+	RateLimitPerSecond float64 `json:"rateLimitPerSecond"`
+	RateLimitBurst     int     `json:"rateLimitBurst"`
+	DailyQuota         int64   `json:"dailyQuota"`
+	TokensValidAfter   int64   `json:"tokensValidAfter"`
+	Scopes             string  `json:"scopes"`
+	TokenDigest        string  `json:"tokenDigest"`
+}
+
+func checkRedisCache(uid, server, digest string) (*cachedSekaiUser, bool) {
 	if HarukiSekaiRedis == nil {
-		return false
+		return nil, false
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	redisKey := fmt.Sprintf("haruki_sekai_api:%s:%s", uid, server)
-	if val, err := HarukiSekaiRedis.Get(ctx, redisKey).Result(); err == nil && val != "" {
-		return true
+	val, err := HarukiSekaiRedis.Get(ctx, redisKey).Bytes()
+	if err != nil || len(val) == 0 {
+		return nil, false
 	}
-	return false
+	var cached cachedSekaiUser
+	if err := sonic.Unmarshal(val, &cached); err != nil {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(cached.TokenDigest), []byte(digest)) != 1 {
+		return nil, false
+	}
+	return &cached, true
 }
 
-func validateUserInDB(uid, credential, server string) (*SekaiUser, error) {
+func validateUserInDB(uid, credential, server string) (*SekaiUser, *SekaiUserServer, error) {
 	var user SekaiUser
 	if err := HarukiSekaiUserDB.Where("id = ?", uid).Take(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fiber.NewError(fiber.StatusUnauthorized, "User not found")
+			return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "User not found")
 		}
-		return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	ok, needsRehash := harukiCredential.Verify(credential, user.Credential)
+	if !ok {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid credential")
 	}
-	if user.Credential != credential {
-		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid credential")
+	if needsRehash {
+		rehashCredential(&user, credential)
 	}
 
 	var us SekaiUserServer
 	if err := HarukiSekaiUserDB.Where("user_id = ? AND server = ?", uid, server).Take(&us).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fiber.NewError(fiber.StatusForbidden, "Not authorized for this server")
+			return nil, nil, fiber.NewError(fiber.StatusForbidden, "Not authorized for this server")
 		}
-		return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
 	}
 
-	return &user, nil
+	return &user, &us, nil
 }
 
-func cacheUserInRedis(uid, server string) {
+func verifyUserCredential(c fiber.Ctx, tokenStr, uid, credential, server string) (*SekaiUser, *SekaiUserServer, error) {
+	key := credentialCacheKey(tokenStr, uid, server)
+	if user, grant, ok := harukiCredentialCache.get(key); ok {
+		return user, grant, nil
+	}
+	if wait, ok := harukiCredentialCache.throttled(uid, c.IP()); ok {
+		c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(wait))
+		return nil, nil, fiber.NewError(fiber.StatusTooManyRequests, "Too many failed attempts")
+	}
+	user, grant, err := validateUserInDB(uid, credential, server)
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) && fe.Code == fiber.StatusUnauthorized {
+			harukiCredentialCache.recordFailure(uid, c.IP())
+		}
+		return nil, nil, err
+	}
+	harukiCredentialCache.put(key, user, grant)
+	return user, grant, nil
+}
+
+func rehashCredential(user *SekaiUser, credential string) {
+	hashed, err := harukiCredential.Hash(credential)
+	if err != nil {
+		return
+	}
+	if err := HarukiSekaiUserDB.Model(&SekaiUser{}).
+		Where("id = ? AND credential = ?", user.ID, user.Credential).
+		Update("credential", hashed).Error; err != nil {
+		return
+	}
+	user.Credential = hashed
+}
+
+func cacheUserInRedis(user *SekaiUser, grant *SekaiUserServer, digest string) {
 	if HarukiSekaiRedis == nil {
 		return
 	}
 
+	val, err := sonic.Marshal(cachedSekaiUser{
+		Remark:             user.Remark,
+		RateLimitPerSecond: user.RateLimitPerSecond,
+		RateLimitBurst:     user.RateLimitBurst,
+		DailyQuota:         user.DailyQuota,
+		TokensValidAfter:   user.TokensValidAfter,
+		Scopes:             grant.Scopes,
+		TokenDigest:        digest,
+	})
+	if err != nil {
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	redisKey := fmt.Sprintf("haruki_sekai_api:%s:%s", uid, server)
-	_ = HarukiSekaiRedis.Set(ctx, redisKey, "1", 12*time.Hour).Err()
+	redisKey := fmt.Sprintf("haruki_sekai_api:%s:%s", user.ID, grant.Server)
+	_ = HarukiSekaiRedis.Set(ctx, redisKey, val, 12*time.Hour).Err()
 }
 
 func validateUserTokenMiddleware() fiber.Handler {
 	return func(c fiber.Ctx) error {
-		if HarukiSekaiUserDB == nil {
+		if harukiAuthMode == authModeNone {
 			return c.Next()
 		}
 
@@ -100,36 +173,72 @@ func validateUserTokenMiddleware() fiber.Handler {
 			return fiber.NewError(fiber.StatusUnauthorized, "Missing token")
 		}
 
+		region, err := resolveServerFromCtx(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		server := string(region)
+
+		if harukiAuthMode == authModeStaticTokens {
+			return validateStaticToken(c, tokenStr, server)
+		}
+
 		_, claims, err := parseJWTToken(tokenStr)
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
 
 		uid, _ := claims["uid"].(string)
-		credential, _ := claims["credential"].(string)
-		if uid == "" || credential == "" {
+		if uid == "" {
 			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token payload")
 		}
 
-		region, err := resolveServerFromCtx(c)
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		jti, _ := claims["jti"].(string)
+		if jti != "" && isTokenRevoked(jti) {
+			return fiber.NewError(fiber.StatusUnauthorized, "Token has been revoked")
+		}
+		c.Locals("sekaiTokenScopes", scopesFromClaims(claims))
+
+		if harukiAuthMode == authModeJWTOnly {
+			return validateJWTClaimsOnly(c, claims, uid, server)
 		}
-		server := string(region)
 
-		if checkRedisCache(uid, server) {
-			c.Locals("sekaiUser", SekaiUser{ID: uid, Credential: credential, Remark: ""})
+		credential, _ := claims["credential"].(string)
+		if credential == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid token payload")
+		}
+		issuedAt, _ := claims.GetIssuedAt()
+
+		digest := tokenDigest(tokenStr)
+		if cached, ok := checkRedisCache(uid, server, digest); ok {
+			if !tokenIssuedAfter(issuedAt, cached.TokensValidAfter) {
+				return fiber.NewError(fiber.StatusUnauthorized, "Token has been revoked")
+			}
+			c.Locals("sekaiUser", SekaiUser{
+				ID:                 uid,
+				Credential:         credential,
+				Remark:             cached.Remark,
+				RateLimitPerSecond: cached.RateLimitPerSecond,
+				RateLimitBurst:     cached.RateLimitBurst,
+				DailyQuota:         cached.DailyQuota,
+				TokensValidAfter:   cached.TokensValidAfter,
+			})
+			c.Locals("sekaiGrantScopes", splitScopes(cached.Scopes))
 			return c.Next()
 		}
 
-		user, err := validateUserInDB(uid, credential, server)
+		user, grant, err := verifyUserCredential(c, tokenStr, uid, credential, server)
 		if err != nil {
 			return err
 		}
+		if !tokenIssuedAfter(issuedAt, user.TokensValidAfter) {
+			return fiber.NewError(fiber.StatusUnauthorized, "Token has been revoked")
+		}
 
-		cacheUserInRedis(uid, server)
+		cacheUserInRedis(user, grant, digest)
 
-		c.Locals("sekaiUser", SekaiUser{ID: uid, Credential: credential, Remark: user.Remark})
+		c.Locals("sekaiUser", *user)
+		c.Locals("sekaiGrantScopes", splitScopes(grant.Scopes))
 		return c.Next()
 	}
 }
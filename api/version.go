@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func getVersion(c fiber.Ctx) error {
+	_, mgr, err := getMgr(c)
+	if err != nil {
+		return err
+	}
+	current, err := mgr.GetCurrentVersion()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fiber.NewError(fiber.StatusNotFound, "version file not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load version file")
+	}
+	return c.JSON(current)
+}
+
+func getVersionHistory(c fiber.Ctx) error {
+	_, mgr, err := getMgr(c)
+	if err != nil {
+		return err
+	}
+	history, err := mgr.GetVersionHistory()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fiber.NewError(fiber.StatusNotFound, "version directory not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load version history")
+	}
+	return c.JSON(history)
+}
+
+func registerHarukiSekaiVersionRoutes(app *fiber.App) {
+	version := newRouteGroup(app, "/version/:server", false)
+
+	version.get("/", scopeInformation, getVersion)
+	version.get("/history", scopeInformation, getVersionHistory)
+}
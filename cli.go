@@ -0,0 +1,262 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"haruki-sekai-api/api"
+	"haruki-sekai-api/client"
+	"haruki-sekai-api/config"
+	"haruki-sekai-api/utils"
+	"haruki-sekai-api/utils/apphash"
+	"haruki-sekai-api/utils/git"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+const cliUsage = `Usage: haruki-sekai-api [command]
+
+Commands:
+  serve                                             start the API server (default)
+  user add --id ID --credential CRED [--remark R] [--servers jp,en] [--scopes profile,ranking]
+                                                    [--rate-limit N] [--burst N] [--daily-quota N]
+  user grant SERVER --id ID [--scopes profile,ranking]
+  token issue --id ID [--credential CRED] [--ttl 720h] [--scopes profile]
+  config check                                      validate haruki-sekai-configs.yaml
+  master update --server SERVER [--once]            check master data updates, --once exits after one check
+  apphash check [--server SERVER]                   check app hash updates for one or all enabled servers
+`
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parseCommandFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return append(positional, fs.Args()...), nil
+}
+
+func runCommand(args []string) int {
+	var err error
+	switch strings.Join(args[:min(len(args), 2)], " ") {
+	case "user add":
+		err = runUserAdd(args[2:])
+	case "user grant":
+		err = runUserGrant(args[2:])
+	case "token issue":
+		err = runTokenIssue(args[2:])
+	case "config check":
+		err = runConfigCheck()
+	case "master update":
+		err = runMasterUpdate(args[2:])
+	case "apphash check":
+		err = runAppHashCheck(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runUserAdd(args []string) error {
+	fs := flag.NewFlagSet("user add", flag.ContinueOnError)
+	var req api.CreateSekaiUserRequest
+	var servers, scopes string
+	fs.StringVar(&req.ID, "id", "", "user id")
+	fs.StringVar(&req.Credential, "credential", "", "user credential")
+	fs.StringVar(&req.Remark, "remark", "", "remark")
+	fs.StringVar(&servers, "servers", "", "comma separated servers to grant")
+	fs.StringVar(&scopes, "scopes", "", "comma separated scopes for the granted servers, all scopes if empty")
+	fs.Float64Var(&req.RateLimitPerSecond, "rate-limit", 0, "requests per second, 0 uses the default")
+	fs.IntVar(&req.RateLimitBurst, "burst", 0, "rate limit burst, 0 uses the default")
+	fs.Int64Var(&req.DailyQuota, "daily-quota", 0, "daily request quota, 0 is unlimited")
+	if _, err := parseCommandFlags(fs, args); err != nil {
+		return err
+	}
+	req.Servers = splitList(servers)
+	req.Scopes = splitList(scopes)
+	if err := api.InitAdminUtils(config.Cfg); err != nil {
+		return err
+	}
+	user, err := api.CreateSekaiUser(req)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created user %s\n", user.ID)
+	return nil
+}
+
+func runUserGrant(args []string) error {
+	fs := flag.NewFlagSet("user grant", flag.ContinueOnError)
+	var uid, scopes string
+	fs.StringVar(&uid, "id", "", "user id")
+	fs.StringVar(&scopes, "scopes", "", "comma separated scopes, all scopes if empty")
+	positional, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || uid == "" {
+		return fmt.Errorf("usage: user grant SERVER --id ID [--scopes ...]")
+	}
+	if err := api.InitAdminUtils(config.Cfg); err != nil {
+		return err
+	}
+	if err := api.GrantSekaiUserServer(uid, positional[0], splitList(scopes)); err != nil {
+		return err
+	}
+	fmt.Printf("granted %s to user %s\n", positional[0], uid)
+	return nil
+}
+
+func runTokenIssue(args []string) error {
+	fs := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	var uid, scopes string
+	var req api.IssueSekaiUserTokenRequest
+	fs.StringVar(&uid, "id", "", "user id")
+	fs.StringVar(&req.Credential, "credential", "", "user credential, required once the stored credential is hashed")
+	fs.StringVar(&req.TTL, "ttl", "", "token lifetime such as 720h, never expires if empty")
+	fs.StringVar(&scopes, "scopes", "", "comma separated scopes embedded in the token")
+	if _, err := parseCommandFlags(fs, args); err != nil {
+		return err
+	}
+	if uid == "" {
+		return fmt.Errorf("--id is required")
+	}
+	req.Scopes = splitList(scopes)
+	if err := api.InitAdminUtils(config.Cfg); err != nil {
+		return err
+	}
+	token, err := api.IssueSekaiUserToken(uid, req)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+func runConfigCheck() error {
+	errs := api.CheckConfig(config.Cfg)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  %v\n", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config check found %d problem(s)", len(errs))
+	}
+	fmt.Println("config ok")
+	return nil
+}
+
+func parseServerFlag(value string) (utils.HarukiSekaiServerRegion, utils.HarukiSekaiServerConfig, error) {
+	server, err := utils.ParseSekaiServerRegion(strings.ToLower(value))
+	if err != nil {
+		return "", utils.HarukiSekaiServerConfig{}, err
+	}
+	serverConfig, ok := config.Cfg.Servers[server]
+	if !ok || !serverConfig.Enabled {
+		return "", utils.HarukiSekaiServerConfig{}, fmt.Errorf("server %s is not enabled", server)
+	}
+	return server, serverConfig, nil
+}
+
+func runMasterUpdate(args []string) error {
+	fs := flag.NewFlagSet("master update", flag.ContinueOnError)
+	var serverFlag string
+	var once bool
+	fs.StringVar(&serverFlag, "server", "", "server region")
+	fs.BoolVar(&once, "once", false, "run a single check and exit instead of following master_updater_cron")
+	if _, err := parseCommandFlags(fs, args); err != nil {
+		return err
+	}
+	server, serverConfig, err := parseServerFlag(serverFlag)
+	if err != nil {
+		return err
+	}
+	if !once && serverConfig.MasterUpdaterCron == "" {
+		return fmt.Errorf("server %s has no master_updater_cron, use --once", server)
+	}
+
+	var harukiGit *git.HarukiGitUpdater
+	if config.Cfg.Git.Enabled {
+		harukiGit = git.NewHarukiGitUpdater(config.Cfg.Git.Username, config.Cfg.Git.Email, config.Cfg.Git.Password, config.Cfg.Proxy)
+	}
+	sessionStore, err := api.OpenSessionStore(config.Cfg)
+	if err != nil {
+		return err
+	}
+	mgr := client.NewSekaiClientManager(server, serverConfig, config.Cfg.AssetUpdaterServers, harukiGit, config.Cfg.Proxy, config.Cfg.JPSekaiCookieURL)
+	mgr.SessionStore = sessionStore
+	if err := mgr.Init(); err != nil {
+		return err
+	}
+	defer func() { _ = mgr.Shutdown() }()
+
+	if once {
+		mgr.CheckSekaiMasterUpdate()
+		mgr.WaitUpdates()
+		return nil
+	}
+
+	sch, err := gocron.NewScheduler()
+	if err != nil {
+		return err
+	}
+	if _, err := sch.NewJob(gocron.CronJob(serverConfig.MasterUpdaterCron, true), gocron.NewTask(mgr.CheckSekaiMasterUpdate)); err != nil {
+		return err
+	}
+	sch.Start()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	_ = sch.Shutdown()
+	mgr.WaitUpdates()
+	return nil
+}
+
+func runAppHashCheck(args []string) error {
+	fs := flag.NewFlagSet("apphash check", flag.ContinueOnError)
+	var serverFlag string
+	fs.StringVar(&serverFlag, "server", "", "server region, all enabled servers if empty")
+	if _, err := parseCommandFlags(fs, args); err != nil {
+		return err
+	}
+	servers := map[utils.HarukiSekaiServerRegion]utils.HarukiSekaiServerConfig{}
+	if serverFlag != "" {
+		server, serverConfig, err := parseServerFlag(serverFlag)
+		if err != nil {
+			return err
+		}
+		servers[server] = serverConfig
+	} else {
+		for server, serverConfig := range config.Cfg.Servers {
+			if serverConfig.Enabled {
+				servers[server] = serverConfig
+			}
+		}
+	}
+	for server, serverConfig := range servers {
+		updater := apphash.NewAppHashUpdater(config.Cfg.AppHashSources, server, &serverConfig.VersionPath)
+		updater.CheckAppVersion()
+	}
+	return nil
+}
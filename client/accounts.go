@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"haruki-sekai-api/utils"
+	"haruki-sekai-api/utils/sessionstore"
+
+	"github.com/bytedance/sonic"
+)
+
+const defaultLoginStagger = 200 * time.Millisecond
+
+type accountEntry struct {
+	key     string
+	digest  string
+	source  string
+	account SekaiAccountInterface
+}
+
+func (mgr *SekaiClientManager) newAccountEntry(path string, idx int, account SekaiAccountInterface) accountEntry {
+	source := path
+	if idx >= 0 {
+		source = fmt.Sprintf("%s[%d]", path, idx)
+	}
+	data, _ := sonic.Marshal(account)
+	sum := sha256.Sum256(data)
+	return accountEntry{
+		key:     fmt.Sprintf("%s|%s|%s", mgr.Server, source, account.GetUserId()),
+		digest:  hex.EncodeToString(sum[:]),
+		source:  source,
+		account: account,
+	}
+}
+
+func validateAccounts(accounts []accountEntry) error {
+	var errs []error
+	tokens := make(map[string]string, len(accounts))
+	for _, entry := range accounts {
+		token := entry.account.GetToken()
+		if token == "" {
+			errs = append(errs, fmt.Errorf("account %s has an empty token", entry.source))
+			continue
+		}
+		if first, ok := tokens[token]; ok {
+			errs = append(errs, fmt.Errorf("account %s has the same token as %s", entry.source, first))
+			continue
+		}
+		tokens[token] = entry.source
+	}
+	return errors.Join(errs...)
+}
+
+func (mgr *SekaiClientManager) newClient(entry accountEntry) *SekaiClient {
+	client := NewSekaiClient(
+		mgr.Server,
+		mgr.ServerConfig,
+		entry.account,
+		mgr.CookieHelper,
+		mgr.VersionHelper,
+		mgr.Proxy,
+	)
+	client.SessionStore = mgr.SessionStore
+	client.accountKey = entry.key
+	client.accountDigest = entry.digest
+	return client
+}
+
+func (mgr *SekaiClientManager) clients() []*SekaiClient {
+	mgr.clientsLock.RLock()
+	defer mgr.clientsLock.RUnlock()
+	return mgr.Clients
+}
+
+func (mgr *SekaiClientManager) setClients(clients []*SekaiClient) {
+	mgr.clientsLock.Lock()
+	mgr.Clients = clients
+	mgr.clientsLock.Unlock()
+}
+
+func parseLoginStagger(serverConfig utils.HarukiSekaiServerConfig) time.Duration {
+	if d, err := time.ParseDuration(serverConfig.LoginStagger); err == nil && d >= 0 {
+		return d
+	}
+	return defaultLoginStagger
+}
+
+func (mgr *SekaiClientManager) initClient(ctx context.Context, c *SekaiClient) (*sessionstore.State, error) {
+	state := c.loadSession(ctx)
+	if err := c.Init(); err != nil {
+		mgr.Logger.Errorf("Error initializing client: %v", err)
+		mgr.markPending(c, err)
+		return nil, err
+	}
+	return state, nil
+}
+
+func (mgr *SekaiClientManager) startClients(ctx context.Context, clients []*SekaiClient) (int, error) {
+	states := make([]*sessionstore.State, len(clients))
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Go(func() { states[i], errs[i] = mgr.initClient(ctx, c) })
+	}
+	wg.Wait()
+
+	var loggedIn atomic.Int64
+	var logins int
+	for i, c := range clients {
+		if errs[i] != nil {
+			continue
+		}
+		var delay time.Duration
+		if states[i] == nil {
+			delay = time.Duration(logins) * mgr.loginStagger
+			logins++
+		}
+		wg.Go(func() {
+			if errs[i] = mgr.resumeOrLogin(ctx, c, states[i], delay); errs[i] == nil {
+				loggedIn.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return int(loggedIn.Load()), err
+		}
+	}
+	return int(loggedIn.Load()), nil
+}
+
+func (mgr *SekaiClientManager) ClientCount() int {
+	return len(mgr.clients())
+}
+
+func (mgr *SekaiClientManager) WatchAccounts() error {
+	if mgr.ServerConfig.AccountReloadInterval == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(mgr.ServerConfig.AccountReloadInterval)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	mgr.Logger.Infof("%s watching %s for account changes every %s", strings.ToUpper(string(mgr.Server)), mgr.ServerConfig.AccountDir, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mgr.stop:
+				return
+			case <-ticker.C:
+				mgr.ReloadAccounts(context.Background())
+			}
+		}
+	}()
+	return nil
+}
+
+func (mgr *SekaiClientManager) stopBackground() {
+	mgr.stopOnce.Do(func() { close(mgr.stop) })
+}
+
+func (mgr *SekaiClientManager) ReloadAccounts(ctx context.Context) {
+	added, removed, active, ok := mgr.swapAccounts()
+	if !ok {
+		return
+	}
+
+	for _, client := range removed {
+		client.deleteSession(ctx)
+		if err := client.Close(); err != nil {
+			mgr.Logger.Warnf("reloadAccounts: error closing client: %v", err)
+		}
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		mgr.Logger.Infof("%s accounts reloaded: %d added, %d removed, %d active", strings.ToUpper(string(mgr.Server)), len(added), len(removed), active)
+	}
+	if len(added) == 0 {
+		return
+	}
+	if _, err := mgr.startClients(ctx, added); err != nil {
+		mgr.Logger.Warnf("reloadAccounts: some new accounts failed to start, retrying in background: %v", err)
+	}
+}
+
+func (mgr *SekaiClientManager) swapAccounts() ([]*SekaiClient, []*SekaiClient, int, bool) {
+	mgr.reloadLock.Lock()
+	defer mgr.reloadLock.Unlock()
+
+	accounts, err := mgr.parseAccounts()
+	if err != nil {
+		mgr.Logger.Warnf("reloadAccounts: keeping current accounts: %v", err)
+		return nil, nil, 0, false
+	}
+
+	wanted := make(map[string]string, len(accounts))
+	existing := make(map[string]string)
+	for _, client := range mgr.clients() {
+		existing[client.accountKey] = client.accountDigest
+	}
+
+	var added []*SekaiClient
+	for _, entry := range accounts {
+		wanted[entry.key] = entry.digest
+		if digest, ok := existing[entry.key]; ok && digest == entry.digest {
+			continue
+		}
+		client := mgr.newClient(entry)
+		client.health.begin()
+		added = append(added, client)
+	}
+
+	mgr.clientsLock.Lock()
+	defer mgr.clientsLock.Unlock()
+	clients := make([]*SekaiClient, 0, len(mgr.Clients)+len(added))
+	var removed []*SekaiClient
+	for _, client := range mgr.Clients {
+		if digest, ok := wanted[client.accountKey]; ok && digest == client.accountDigest {
+			clients = append(clients, client)
+		} else {
+			removed = append(removed, client)
+		}
+	}
+	mgr.Clients = append(clients, added...)
+	return added, removed, len(mgr.Clients), true
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"haruki-sekai-api/utils"
+)
+
+func newTestAccountManager(t *testing.T) (*SekaiClientManager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	mgr := newTestManager(utils.HarukiSekaiServerRegionEN)
+	mgr.ServerConfig = utils.HarukiSekaiServerConfig{AccountDir: dir, AESKeyHex: testAESKeyHex, AESIVHex: testAESIVHex}
+	return mgr, dir
+}
+
+func writeAccountFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAccountsRejectsInvalidTokens(t *testing.T) {
+	cases := map[string]string{
+		"empty token":     `[{"userId":"1","credential":"a"},{"userId":"2","credential":""}]`,
+		"duplicate token": `[{"userId":"1","credential":"a"},{"userId":"2","credential":"a"}]`,
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr, dir := newTestAccountManager(t)
+			writeAccountFile(t, dir, "accounts.json", content)
+			if _, err := mgr.parseAccounts(); err == nil || !strings.Contains(err.Error(), "accounts.json[1]") {
+				t.Fatalf("parseAccounts error = %v, want one naming accounts.json[1]", err)
+			}
+		})
+	}
+}
+
+func TestParseAccountsKeysBySource(t *testing.T) {
+	mgr, dir := newTestAccountManager(t)
+	writeAccountFile(t, dir, "a.json", `{"userId":"1","credential":"a"}`)
+	writeAccountFile(t, dir, "b.json", `[{"userId":"1","credential":"b"},{"userId":"2","credential":"c"}]`)
+
+	accounts, err := mgr.parseAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for _, entry := range accounts {
+		keys[entry.key] = true
+	}
+	if len(accounts) != 3 || len(keys) != 3 {
+		t.Fatalf("parsed %d accounts with %d keys, want 3 distinct", len(accounts), len(keys))
+	}
+}
+
+func TestSwapAccountsReloadsChangedAccounts(t *testing.T) {
+	mgr, dir := newTestAccountManager(t)
+	writeAccountFile(t, dir, "a.json", `{"userId":"1","credential":"a","deviceId":"old"}`)
+	writeAccountFile(t, dir, "b.json", `{"userId":"2","credential":"b"}`)
+
+	added, removed, active, ok := mgr.swapAccounts()
+	if !ok || len(added) != 2 || len(removed) != 0 || active != 2 {
+		t.Fatalf("initial swap = %d added, %d removed, %d active, %v", len(added), len(removed), active, ok)
+	}
+	unchanged := added[1]
+
+	writeAccountFile(t, dir, "a.json", `{"userId":"1","credential":"a","deviceId":"new"}`)
+	added, removed, active, ok = mgr.swapAccounts()
+	if !ok || len(added) != 1 || len(removed) != 1 || active != 2 {
+		t.Fatalf("reload swap = %d added, %d removed, %d active, %v", len(added), len(removed), active, ok)
+	}
+	if added[0].Account.GetDeviceId() != "new" || removed[0].Account.GetDeviceId() != "old" {
+		t.Fatal("changed account was not replaced")
+	}
+	if !slices.Contains(mgr.clients(), unchanged) {
+		t.Fatal("unchanged account was replaced")
+	}
+
+	writeAccountFile(t, dir, "b.json", `{"userId":"2","credential":""}`)
+	if _, _, _, ok := mgr.swapAccounts(); ok {
+		t.Fatal("reload with an empty token should keep the current accounts")
+	}
+	if got := mgr.ClientCount(); got != 2 {
+		t.Fatalf("client count = %d, want 2", got)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"haruki-sekai-api/utils"
 	"haruki-sekai-api/utils/logger"
+	"haruki-sekai-api/utils/metrics"
+	"haruki-sekai-api/utils/sessionstore"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -17,6 +21,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/jtacoma/uritemplates"
 	"github.com/samber/lo"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
 )
 
 type SekaiClient struct {
@@ -28,10 +34,22 @@ type SekaiClient struct {
 	Proxy         string
 	Logger        *logger.Logger
 	Cryptor       *SekaiCryptor
-	APILock       *sync.Mutex
+	APISlots      *semaphore.Weighted
+	maxRequests   int64
 	HeaderLock    *sync.Mutex
 	Session       *resty.Client
 	Headers       map[string]string
+	loggedIn      atomic.Bool
+	stateLock     sync.Mutex
+	lastLoginErr  error
+	lastLoginAt   time.Time
+	loginFlight   singleflight.Group
+	SessionStore  sessionstore.Store
+	sessionDirty  atomic.Bool
+	initialized   atomic.Bool
+	health        clientHealth
+	accountKey    string
+	accountDigest string
 }
 
 func NewSekaiClient(
@@ -47,6 +65,11 @@ func NewSekaiClient(
 		panic(err)
 	}
 
+	maxRequests := int64(serverConfig.MaxConcurrentRequests)
+	if maxRequests <= 0 {
+		maxRequests = 1
+	}
+
 	headers := make(map[string]string, len(serverConfig.Headers))
 	for k, v := range serverConfig.Headers {
 		headers[k] = v
@@ -59,9 +82,11 @@ func NewSekaiClient(
 		CookieHelper:  cookieHelper,
 		VersionHelper: versionHelper,
 		Proxy:         proxy,
+		Logger:        logger.NewLogger(fmt.Sprintf("SekaiClient%s", strings.ToUpper(string(server))), "INFO", nil),
 		Cryptor:       cryptor,
 		Headers:       headers,
-		APILock:       &sync.Mutex{},
+		APISlots:      semaphore.NewWeighted(maxRequests),
+		maxRequests:   maxRequests,
 		HeaderLock:    &sync.Mutex{},
 	}
 
@@ -75,10 +100,22 @@ func (c *SekaiClient) ParseCookies(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	c.Headers["Cookie"] = cookie
+	c.setCookie(cookie)
 	return nil
 }
 
+func (c *SekaiClient) cookie() string {
+	c.HeaderLock.Lock()
+	defer c.HeaderLock.Unlock()
+	return c.Headers["Cookie"]
+}
+
+func (c *SekaiClient) setCookie(cookie string) {
+	c.HeaderLock.Lock()
+	c.Headers["Cookie"] = cookie
+	c.HeaderLock.Unlock()
+}
+
 func (c *SekaiClient) ParseVersion() error {
 	if err := c.VersionHelper.GetAppVersion(); err != nil {
 		return err
@@ -95,6 +132,37 @@ func (c *SekaiClient) ParseVersion() error {
 	return nil
 }
 
+func (c *SekaiClient) serverLabel() string {
+	return string(c.Server)
+}
+
+func (c *SekaiClient) recordException(err error) {
+	if t := ExceptionType(err); t != "" {
+		metrics.ClientExceptions.Inc(c.serverLabel(), t)
+	}
+}
+
+func (c *SekaiClient) recordLogin(err error) {
+	if err != nil {
+		c.recordException(err)
+	}
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	c.lastLoginAt = time.Now()
+	c.lastLoginErr = err
+	c.loggedIn.Store(err == nil)
+}
+
+func (c *SekaiClient) IsLoggedIn() bool {
+	return c.loggedIn.Load()
+}
+
+func (c *SekaiClient) LastLoginError() (error, time.Time) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.lastLoginErr, c.lastLoginAt
+}
+
 func (c *SekaiClient) Init() error {
 	c.Session = resty.New()
 	c.Session.
@@ -110,13 +178,15 @@ func (c *SekaiClient) Init() error {
 	if c.Proxy != "" {
 		c.Session.SetProxy(c.Proxy)
 	}
-	c.Logger = logger.NewLogger(fmt.Sprintf("SekaiClient%s", strings.ToUpper(string(c.Server))), "INFO", nil)
-	if err := c.ParseCookies(context.Background()); err != nil {
-		return err
+	if c.cookie() == "" {
+		if err := c.ParseCookies(context.Background()); err != nil {
+			return err
+		}
 	}
 	if err := c.ParseVersion(); err != nil {
 		return err
 	}
+	c.initialized.Store(true)
 	return nil
 }
 
@@ -164,17 +234,22 @@ func (c *SekaiClient) handleResponse(response resty.Response) (any, error) {
 	return nil, NewSekaiUnknownClientException(response.StatusCode(), string(response.Body()))
 }
 
-func (c *SekaiClient) prepareRequest(ctx context.Context, data any, params map[string]any) (*resty.Request, error) {
+type sentSession struct {
+	token  string
+	cookie string
+}
+
+func (c *SekaiClient) prepareRequest(ctx context.Context, data any, params map[string]any) (*resty.Request, sentSession, error) {
 	req := c.Session.R()
 	req.SetContext(ctx)
 
 	c.HeaderLock.Lock()
 	req.SetHeaders(c.Headers)
-	currentToken := c.Headers["X-Session-Token"]
+	sent := sentSession{token: c.Headers["X-Session-Token"], cookie: c.Headers["Cookie"]}
 	c.HeaderLock.Unlock()
 	c.Logger.Debugf("account #%s using session token: %s...",
 		c.Account.GetUserId(),
-		truncateString(currentToken, 80))
+		truncateString(sent.token, 80))
 
 	req.Header.Set("X-Request-Id", uuid.New().String())
 
@@ -188,12 +263,12 @@ func (c *SekaiClient) prepareRequest(ctx context.Context, data any, params map[s
 		packedData, err := c.Cryptor.Pack(data)
 		if err != nil {
 			c.Logger.Errorf("pack error: %v", err)
-			return nil, err
+			return nil, sent, err
 		}
 		req.SetBody(packedData)
 	}
 
-	return req, nil
+	return req, sent, nil
 }
 
 func (c *SekaiClient) handleExecutionError(execErr error, attempt int) error {
@@ -206,11 +281,23 @@ func (c *SekaiClient) handleExecutionError(execErr error, attempt int) error {
 	return execErr
 }
 
-func (c *SekaiClient) updateSessionToken(response *resty.Response) {
+func (c *SekaiClient) sessionToken() string {
+	c.HeaderLock.Lock()
+	defer c.HeaderLock.Unlock()
+	return c.Headers["X-Session-Token"]
+}
+
+func (c *SekaiClient) updateSessionToken(response *resty.Response, sentToken string) {
 	if v := response.Header().Get("X-Session-Token"); v != "" {
 		c.HeaderLock.Lock()
 		oldToken := c.Headers["X-Session-Token"]
+		if oldToken != sentToken {
+			c.HeaderLock.Unlock()
+			c.Logger.Debugf("account #%s session token was replaced by another request, keeping current token", c.Account.GetUserId())
+			return
+		}
 		c.Headers["X-Session-Token"] = v
+		c.sessionDirty.Store(true)
 		c.Logger.Debugf("account #%s session token updated (old: %s..., new: %s...)",
 			c.Account.GetUserId(),
 			truncateString(oldToken, 80),
@@ -221,18 +308,49 @@ func (c *SekaiClient) updateSessionToken(response *resty.Response) {
 	}
 }
 
-func (c *SekaiClient) handleSessionError() error {
+func (c *SekaiClient) relogin(ctx context.Context) (*utils.HarukiSekaiLoginResponse, error) {
+	v, err, _ := c.loginFlight.Do("login", func() (any, error) {
+		return c.Login(ctx)
+	})
+	retData, _ := v.(*utils.HarukiSekaiLoginResponse)
+	return retData, err
+}
+
+func (c *SekaiClient) Relogin(ctx context.Context) (*utils.HarukiSekaiLoginResponse, error) {
+	if err := c.APISlots.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.APISlots.Release(1)
+	if c.Session == nil {
+		return nil, errNoSession
+	}
+	return c.relogin(ctx)
+}
+
+func (c *SekaiClient) handleSessionError(sentToken string) error {
+	if c.sessionToken() != sentToken {
+		c.Logger.Debugf("account #%s session was already refreshed, retrying...", c.Account.GetUserId())
+		return nil
+	}
 	c.Logger.Warnf("account #%s session expired, re-logging in...", c.Account.GetUserId())
-	if _, err := c.Login(context.Background()); err != nil {
+	metrics.SessionRelogins.Inc(c.serverLabel(), "session_expired")
+	if _, err := c.relogin(context.Background()); err != nil {
 		c.Logger.Errorf("re-login failed: %v", err)
 		return err
 	}
 	return nil
 }
 
-func (c *SekaiClient) handleCookieExpiredError(ctx context.Context) error {
-	c.Logger.Warnf("cookies expired, re-parsing cookies...")
-	if err := c.ParseCookies(ctx); err != nil {
+func (c *SekaiClient) handleCookieExpiredError(ctx context.Context, sentCookie string) error {
+	_, err, _ := c.loginFlight.Do("cookies", func() (any, error) {
+		if c.cookie() != sentCookie {
+			return nil, nil
+		}
+		c.Logger.Warnf("cookies expired, re-parsing cookies...")
+		metrics.CookieRefreshes.Inc(c.serverLabel())
+		return nil, c.ParseCookies(ctx)
+	})
+	if err != nil {
 		c.Logger.Errorf("parse cookies failed: %v", err)
 		return err
 	}
@@ -245,14 +363,15 @@ func (c *SekaiClient) handleUpgradeError(ctx context.Context) error {
 		return NewUpgradeRequiredError()
 	}
 	c.Logger.Warnf("%s server detected new data, re-logging in...", strings.ToUpper(string(c.Server)))
-	if _, err := c.Login(ctx); err != nil {
+	metrics.SessionRelogins.Inc(c.serverLabel(), "new_data")
+	if _, err := c.relogin(ctx); err != nil {
 		c.Logger.Errorf("re-login failed: %v", err)
 		return err
 	}
 	return nil
 }
 
-func (c *SekaiClient) handleResponseError(ctx context.Context, respErr error, response *resty.Response, attempt int) (error, bool) {
+func (c *SekaiClient) handleResponseError(ctx context.Context, respErr error, response *resty.Response, attempt int, sent sentSession) (error, bool) {
 	var (
 		se *SessionError
 		ce *CookieExpiredError
@@ -262,13 +381,13 @@ func (c *SekaiClient) handleResponseError(ctx context.Context, respErr error, re
 
 	switch {
 	case errors.As(respErr, &se):
-		err := c.handleSessionError()
+		err := c.handleSessionError(sent.token)
 		if err != nil {
 			return err, true
 		}
 		return nil, false
 	case errors.As(respErr, &ce):
-		err := c.handleCookieExpiredError(ctx)
+		err := c.handleCookieExpiredError(ctx, sent.cookie)
 		if err != nil {
 			return err, true
 		}
@@ -295,12 +414,14 @@ func (c *SekaiClient) handleResponseError(ctx context.Context, respErr error, re
 }
 
 func (c *SekaiClient) CallAPI(ctx context.Context, path string, method string, data any, params map[string]any) (*resty.Response, error) {
-	c.Logger.Debugf("account #%s attempting to acquire API lock for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
-	c.APILock.Lock()
-	c.Logger.Debugf("account #%s acquired API lock for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
+	c.Logger.Debugf("account #%s attempting to acquire API slot for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
+	if err := c.APISlots.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	c.Logger.Debugf("account #%s acquired API slot for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
 	defer func() {
-		c.APILock.Unlock()
-		c.Logger.Debugf("account #%s released API lock for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
+		c.APISlots.Release(1)
+		c.Logger.Debugf("account #%s released API slot for %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
 	}()
 
 	uri := fmt.Sprintf("%s/api%s", c.ServerConfig.APIURL, path)
@@ -320,29 +441,42 @@ func (c *SekaiClient) CallAPI(ctx context.Context, path string, method string, d
 	c.Logger.Infof("account #%s %s %s", c.Account.GetUserId(), strings.ToUpper(method), path)
 
 	if c.Session == nil {
-		return nil, fmt.Errorf("resty client is nil")
+		return nil, errNoSession
 	}
 
 	var lastErr error
 	maxRetries := 4
 	retryCount := 0
+	attempts := 0
+	metricPath := metrics.NormalizePath(path)
 
 	for retryCount < maxRetries {
 		retryCount++
+		attempts++
 		attemptNum := retryCount
+		if attempts > 1 {
+			metrics.UpstreamRetries.Inc(c.serverLabel(), "client")
+		}
 
-		req, err := c.prepareRequest(ctx, data, params)
+		req, sent, err := c.prepareRequest(ctx, data, params)
 		if err != nil {
 			return nil, err
 		}
 
+		startedAt := time.Now()
 		response, execErr := req.Execute(strings.ToUpper(method), url)
+		statusLabel := "error"
+		if execErr == nil {
+			statusLabel = strconv.Itoa(response.StatusCode())
+		}
+		metrics.UpstreamRequestDuration.Observe(time.Since(startedAt).Seconds(), c.serverLabel(), metricPath, statusLabel)
 		if execErr != nil {
 			lastErr = c.handleExecutionError(execErr, attemptNum)
 		} else {
-			c.updateSessionToken(response)
+			c.updateSessionToken(response, sent.token)
 			if _, respErr := c.handleResponse(*response); respErr != nil {
-				err, shouldReturn := c.handleResponseError(ctx, respErr, response, attemptNum)
+				c.recordException(respErr)
+				err, shouldReturn := c.handleResponseError(ctx, respErr, response, attemptNum, sent)
 				if shouldReturn {
 					return nil, err
 				}
@@ -388,6 +522,10 @@ func (c *SekaiClient) Patch(ctx context.Context, path string, data any, params m
 }
 
 func (c *SekaiClient) Close() error {
+	if err := c.APISlots.Acquire(context.Background(), c.maxRequests); err != nil {
+		return err
+	}
+	defer c.APISlots.Release(c.maxRequests)
 	if c.Session != nil {
 		c.Session = nil
 	}
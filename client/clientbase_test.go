@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"haruki-sekai-api/utils"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	testAESKeyHex = "00112233445566778899aabbccddeeff"
+	testAESIVHex  = "00112233445566778899aabbccddeeff"
+)
+
+func newTestClient(t *testing.T, server utils.HarukiSekaiServerRegion, apiURL string, cookieURL string, maxRequests int) *SekaiClient {
+	t.Helper()
+	cfg := utils.HarukiSekaiServerConfig{
+		APIURL:                apiURL,
+		AESKeyHex:             testAESKeyHex,
+		AESIVHex:              testAESIVHex,
+		MaxConcurrentRequests: maxRequests,
+	}
+	c := NewSekaiClient(server, cfg, &SekaiAccountCP{SekaiAccountCommonBase: SekaiAccountCommonBase{UserId: "1"}, Credential: "credential"},
+		&SekaiCookieHelper{url: cookieURL}, &SekaiVersionHelper{}, "")
+	c.Session = resty.New()
+	return c
+}
+
+func TestCallAPIConcurrentCookieRefresh(t *testing.T) {
+	cryptor, err := NewSekaiCryptorFromHex(testAESKeyHex, testAESIVHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := cryptor.Pack(map[string]any{"ok": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 8
+	var cookieFetches, tokens atomic.Int64
+	arrived := make(chan struct{})
+	var arrivedOnce sync.Once
+	var waiting atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cookie" {
+			cookieFetches.Add(1)
+			w.Header().Set("Set-Cookie", "fresh=1")
+			return
+		}
+		if r.Header.Get("Cookie") != "fresh=1" {
+			if waiting.Add(1) == requests {
+				arrivedOnce.Do(func() { close(arrived) })
+			}
+			<-arrived
+			w.Header().Set("Content-Type", "text/xml")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Session-Token", "token"+string(rune('a'+tokens.Add(1))))
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, utils.HarukiSekaiServerRegionJP, srv.URL, srv.URL+"/cookie", requests)
+	c.setCookie("stale=1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Go(func() {
+			if _, err := c.Get(context.Background(), "/user/{userId}/profile", nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := cookieFetches.Load(); n != 1 {
+		t.Fatalf("cookie fetched %d times, want 1", n)
+	}
+	if got := c.cookie(); got != "fresh=1" {
+		t.Fatalf("cookie = %q", got)
+	}
+	if c.sessionToken() == "" {
+		t.Fatal("session token was not updated")
+	}
+}
@@ -75,6 +75,16 @@ func (c *SekaiClient) updateHeadersFromLogin(retData *utils.HarukiSekaiLoginResp
 }
 
 func (c *SekaiClient) Login(ctx context.Context) (*utils.HarukiSekaiLoginResponse, error) {
+	retData, err := c.login(ctx)
+	c.recordLogin(err)
+	if err == nil {
+		c.sessionDirty.Store(true)
+		c.persistSession(context.WithoutCancel(ctx))
+	}
+	return retData, err
+}
+
+func (c *SekaiClient) login(ctx context.Context) (*utils.HarukiSekaiLoginResponse, error) {
 	loginMsgpack, err := c.Account.Dump()
 	if err != nil {
 		return nil, err
@@ -144,9 +154,14 @@ func (c *SekaiClient) Login(ctx context.Context) (*utils.HarukiSekaiLoginRespons
 }
 
 func (c *SekaiClient) GetCPMySekaiImage(path string) ([]byte, error) {
-	c.APILock.Lock()
-	defer c.APILock.Unlock()
 	ctx := context.Background()
+	if err := c.APISlots.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer c.APISlots.Release(1)
+	if c.Session == nil {
+		return nil, errNoSession
+	}
 	pathNew := strings.TrimPrefix(path, "/")
 	imageURL := fmt.Sprintf("%s/image/mysekai-photo/%s", c.ServerConfig.APIURL, pathNew)
 	cli := *c.Session
@@ -155,7 +170,9 @@ func (c *SekaiClient) GetCPMySekaiImage(path string) ([]byte, error) {
 	}
 	req := *cli.R()
 	req.SetContext(ctx)
+	c.HeaderLock.Lock()
 	req.SetHeaders(c.Headers)
+	c.HeaderLock.Unlock()
 	resp, err := req.Get(imageURL)
 	if err != nil {
 		return nil, err
@@ -1,6 +1,11 @@
 package client
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var errNoSession = errors.New("resty client is nil")
 
 type SekaiClientException struct {
 	msg string
@@ -87,3 +92,41 @@ func NewSekaiUnknownClientException(statusCode int, response string) error {
 		Response:             response,
 	}
 }
+
+func ExceptionType(err error) string {
+	var (
+		noReturn   *SekaiNoReturnError
+		signature  *SekaiSignatureError
+		account    *SekaiAccountError
+		session    *SessionError
+		cookie     *CookieExpiredError
+		update     *UpdateRequiredError
+		upgrade    *UpgradeRequiredError
+		maintain   *UnderMaintenanceError
+		unknown    *UnknownSekaiClientException
+		clientBase *SekaiClientException
+	)
+	switch {
+	case errors.As(err, &noReturn):
+		return "SekaiNoReturnError"
+	case errors.As(err, &signature):
+		return "SekaiSignatureError"
+	case errors.As(err, &account):
+		return "SekaiAccountError"
+	case errors.As(err, &session):
+		return "SessionError"
+	case errors.As(err, &cookie):
+		return "CookieExpiredError"
+	case errors.As(err, &update):
+		return "UpdateRequiredError"
+	case errors.As(err, &upgrade):
+		return "UpgradeRequiredError"
+	case errors.As(err, &maintain):
+		return "UnderMaintenanceError"
+	case errors.As(err, &unknown):
+		return "UnknownSekaiClientException"
+	case errors.As(err, &clientBase):
+		return "SekaiClientException"
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"haruki-sekai-api/utils"
+)
+
+const (
+	defaultClientFailureThreshold = 3
+	defaultClientQuarantine       = time.Minute
+	defaultLoginRetry             = 5 * time.Second
+	maxClientQuarantine           = 30 * time.Minute
+	clientProbeInterval           = 5 * time.Second
+	clientQuarantineJitter        = 0.2
+	maxConcurrentProbes           = 3
+)
+
+type clientHealthPolicy struct {
+	threshold     int
+	quarantine    time.Duration
+	loginRetry    time.Duration
+	maxQuarantine time.Duration
+	jitter        float64
+}
+
+func newClientHealthPolicy(serverConfig utils.HarukiSekaiServerConfig) clientHealthPolicy {
+	policy := clientHealthPolicy{
+		threshold:     serverConfig.ClientFailureThreshold,
+		quarantine:    defaultClientQuarantine,
+		loginRetry:    defaultLoginRetry,
+		maxQuarantine: maxClientQuarantine,
+		jitter:        clientQuarantineJitter,
+	}
+	if policy.threshold <= 0 {
+		policy.threshold = defaultClientFailureThreshold
+	}
+	if d, err := time.ParseDuration(serverConfig.ClientQuarantine); err == nil && d > 0 {
+		policy.quarantine = d
+	}
+	if policy.maxQuarantine < policy.quarantine {
+		policy.maxQuarantine = policy.quarantine
+	}
+	return policy
+}
+
+func (p clientHealthPolicy) backoff(base time.Duration, quarantines int) time.Duration {
+	d := base
+	for i := 1; i < quarantines && d < p.maxQuarantine; i++ {
+		d *= 2
+	}
+	return min(d, p.maxQuarantine)
+}
+
+func (p clientHealthPolicy) withJitter(d time.Duration) time.Duration {
+	if p.jitter <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Float64()*p.jitter*float64(d))
+}
+
+type clientHealth struct {
+	lock                sync.Mutex
+	consecutiveFailures int
+	quarantines         int
+	quarantinedUntil    time.Time
+	pending             bool
+	starting            bool
+	lastErr             error
+}
+
+func (h *clientHealth) begin() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pending = true
+	h.starting = true
+}
+
+func (h *clientHealth) success() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	readmitted := h.quarantines > 0
+	h.consecutiveFailures = 0
+	h.quarantines = 0
+	h.quarantinedUntil = time.Time{}
+	h.pending = false
+	h.starting = false
+	h.lastErr = nil
+	return readmitted
+}
+
+func (h *clientHealth) failure(err error, policy clientHealthPolicy, now time.Time) time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.consecutiveFailures++
+	h.lastErr = err
+	if h.quarantines == 0 && h.consecutiveFailures < policy.threshold {
+		return 0
+	}
+	return h.quarantine(policy, now)
+}
+
+func (h *clientHealth) loginFailed(err error, policy clientHealthPolicy, now time.Time) time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.consecutiveFailures++
+	h.lastErr = err
+	h.pending = true
+	h.starting = false
+	return h.quarantine(policy, now)
+}
+
+func (h *clientHealth) quarantine(policy clientHealthPolicy, now time.Time) time.Duration {
+	base := policy.quarantine
+	if h.pending {
+		base = policy.loginRetry
+	}
+	h.quarantines++
+	d := policy.withJitter(policy.backoff(base, h.quarantines))
+	h.quarantinedUntil = now.Add(d)
+	return d
+}
+
+func (h *clientHealth) quarantined() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.quarantines > 0 || h.starting
+}
+
+func (h *clientHealth) isPending() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.pending
+}
+
+func (h *clientHealth) probeDue(now time.Time) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return !h.starting && h.quarantines > 0 && !now.Before(h.quarantinedUntil)
+}
+
+func countsAgainstClient(err error) bool {
+	var (
+		upgrade  *UpgradeRequiredError
+		update   *UpdateRequiredError
+		maintain *UnderMaintenanceError
+		unknown  *UnknownSekaiClientException
+		ne       net.Error
+	)
+	switch {
+	case err == nil,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, errNoSession),
+		errors.As(err, &upgrade),
+		errors.As(err, &update),
+		errors.As(err, &maintain),
+		errors.As(err, &ne):
+		return false
+	case errors.As(err, &unknown):
+		return unknown.StatusCode < http.StatusInternalServerError
+	}
+	return true
+}
+
+func (mgr *SekaiClientManager) recordClientResult(c *SekaiClient, err error) {
+	if err == nil {
+		if c.health.success() {
+			mgr.Logger.Infof("%s account #%s re-admitted", strings.ToUpper(string(mgr.Server)), c.Account.GetUserId())
+		}
+		return
+	}
+	if !countsAgainstClient(err) {
+		return
+	}
+	if d := c.health.failure(err, mgr.healthPolicy, time.Now()); d > 0 {
+		mgr.Logger.Warnf("%s account #%s quarantined for %s: %v", strings.ToUpper(string(mgr.Server)), c.Account.GetUserId(), d, err)
+	}
+}
+
+func (mgr *SekaiClientManager) markPending(c *SekaiClient, err error) {
+	d := c.health.loginFailed(err, mgr.healthPolicy, time.Now())
+	mgr.Logger.Warnf("%s account #%s login failed, retrying in %s: %v", strings.ToUpper(string(mgr.Server)), c.Account.GetUserId(), d, err)
+}
+
+func (mgr *SekaiClientManager) probeClients(ctx context.Context, now time.Time) {
+	slots := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+	for _, c := range mgr.clients() {
+		if !c.health.probeDue(now) {
+			continue
+		}
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			mgr.probeClient(ctx, c)
+		})
+	}
+	wg.Wait()
+}
+
+func (mgr *SekaiClientManager) probeClient(ctx context.Context, c *SekaiClient) {
+	if !c.initialized.Load() {
+		if state, err := mgr.initClient(ctx, c); err == nil {
+			_ = mgr.resumeOrLogin(ctx, c, state, 0)
+		}
+		return
+	}
+	if _, err := c.Relogin(ctx); err != nil {
+		d := c.health.failure(err, mgr.healthPolicy, time.Now())
+		mgr.Logger.Warnf("%s account #%s probe failed, retrying in %s: %v", strings.ToUpper(string(mgr.Server)), c.Account.GetUserId(), d, err)
+		return
+	}
+	mgr.setMaintenance(false)
+	mgr.recordClientResult(c, nil)
+}
+
+func (mgr *SekaiClientManager) runHealthProbe() {
+	ticker := time.NewTicker(clientProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mgr.stop:
+			return
+		case <-ticker.C:
+			mgr.probeClients(context.Background(), time.Now())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"haruki-sekai-api/utils"
+)
+
+var errTestClient = errors.New("session expired")
+
+func testHealthPolicy() clientHealthPolicy {
+	return clientHealthPolicy{
+		threshold:     3,
+		quarantine:    time.Minute,
+		loginRetry:    5 * time.Second,
+		maxQuarantine: 4 * time.Minute,
+	}
+}
+
+func TestClientHealthThreshold(t *testing.T) {
+	policy := testHealthPolicy()
+	now := time.Now()
+	var h clientHealth
+
+	for i := 1; i < policy.threshold; i++ {
+		if d := h.failure(errTestClient, policy, now); d != 0 {
+			t.Fatalf("failure %d quarantined for %s before the threshold", i, d)
+		}
+		if h.quarantined() {
+			t.Fatalf("quarantined after %d failures", i)
+		}
+	}
+	if d := h.failure(errTestClient, policy, now); d != policy.quarantine {
+		t.Fatalf("quarantine = %s, want %s", d, policy.quarantine)
+	}
+	if !h.quarantined() {
+		t.Fatal("client should be quarantined at the threshold")
+	}
+
+	h.success()
+	if d := h.failure(errTestClient, policy, now); d != 0 || h.quarantined() {
+		t.Fatal("success should reset the failure count")
+	}
+}
+
+func TestClientHealthQuarantineBackoff(t *testing.T) {
+	policy := testHealthPolicy()
+	policy.threshold = 1
+	now := time.Now()
+	var h clientHealth
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		if d := h.failure(errTestClient, policy, now); d != want {
+			t.Fatalf("quarantine = %s, want %s", d, want)
+		}
+	}
+	if h.probeDue(now.Add(4*time.Minute - time.Second)) {
+		t.Fatal("probe due before the quarantine ended")
+	}
+	if !h.probeDue(now.Add(4 * time.Minute)) {
+		t.Fatal("probe not due after the quarantine ended")
+	}
+	if !h.success() {
+		t.Fatal("success should report the client as re-admitted")
+	}
+	if h.quarantined() || h.probeDue(now.Add(time.Hour)) {
+		t.Fatal("re-admitted client is still quarantined")
+	}
+}
+
+func TestClientHealthPendingLogin(t *testing.T) {
+	policy := testHealthPolicy()
+	now := time.Now()
+	var h clientHealth
+
+	h.begin()
+	if !h.quarantined() || !h.isPending() {
+		t.Fatal("starting client should be pending and out of rotation")
+	}
+	if h.probeDue(now.Add(time.Hour)) {
+		t.Fatal("starting client must not be probed")
+	}
+	if d := h.loginFailed(errTestClient, policy, now); d != policy.loginRetry {
+		t.Fatalf("login retry = %s, want %s", d, policy.loginRetry)
+	}
+	if d := h.loginFailed(errTestClient, policy, now); d != 2*policy.loginRetry {
+		t.Fatalf("login retry = %s, want %s", d, 2*policy.loginRetry)
+	}
+	if !h.probeDue(now.Add(2 * policy.loginRetry)) {
+		t.Fatal("pending client should be probed after the retry delay")
+	}
+	h.success()
+	if h.isPending() || h.quarantined() {
+		t.Fatal("logged in client is still pending")
+	}
+}
+
+func TestClientHealthJitter(t *testing.T) {
+	policy := testHealthPolicy()
+	policy.threshold = 1
+	policy.jitter = 0.2
+	now := time.Now()
+	for range 20 {
+		var h clientHealth
+		d := h.failure(errTestClient, policy, now)
+		if d < policy.quarantine || d > policy.quarantine+policy.quarantine/5 {
+			t.Fatalf("jittered quarantine = %s, want within 20%% above %s", d, policy.quarantine)
+		}
+		if h.probeDue(now.Add(d-time.Nanosecond)) || !h.probeDue(now.Add(d)) {
+			t.Fatal("probe deadline does not match the jittered quarantine")
+		}
+	}
+}
+
+func TestServerErrorDoesNotQuarantine(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	mgr := newTestManager(utils.HarukiSekaiServerRegionEN)
+	mgr.healthPolicy = testHealthPolicy()
+	c := newTestClient(t, utils.HarukiSekaiServerRegionEN, srv.URL, "", 1)
+	for range mgr.healthPolicy.threshold + 1 {
+		_, err := c.Get(context.Background(), "/system", nil)
+		var unknown *UnknownSekaiClientException
+		if !errors.As(err, &unknown) || unknown.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Get error = %v, want a 500 UnknownSekaiClientException", err)
+		}
+		mgr.recordClientResult(c, err)
+	}
+	if c.health.quarantined() {
+		t.Fatal("server errors must not quarantine the account")
+	}
+
+	for range mgr.healthPolicy.threshold {
+		mgr.recordClientResult(c, NewSekaiUnknownClientException(http.StatusForbidden, ""))
+	}
+	if !c.health.quarantined() {
+		t.Fatal("account errors should still quarantine the account")
+	}
+}
+
+func TestCountsAgainstClient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{errNoSession, false},
+		{NewUnderMaintenanceError(), false},
+		{NewSekaiUnknownClientException(http.StatusBadGateway, ""), false},
+		{NewSekaiUnknownClientException(http.StatusForbidden, ""), true},
+		{NewSessionError(), true},
+	}
+	for _, tc := range cases {
+		if got := countsAgainstClient(tc.err); got != tc.want {
+			t.Errorf("countsAgainstClient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -7,15 +7,26 @@ import (
 	"haruki-sekai-api/utils"
 	"haruki-sekai-api/utils/git"
 	"haruki-sekai-api/utils/logger"
+	"haruki-sekai-api/utils/masterdata"
+	"haruki-sekai-api/utils/metrics"
+	"haruki-sekai-api/utils/sessionstore"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/go-resty/resty/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	gameAPICallTimeout = 45 * time.Second
+	maintenanceTTL     = 2 * time.Minute
 )
 
 type SekaiClientManager struct {
@@ -23,13 +34,55 @@ type SekaiClientManager struct {
 	ServerConfig        utils.HarukiSekaiServerConfig
 	VersionHelper       *SekaiVersionHelper
 	CookieHelper        *SekaiCookieHelper
+	MasterStore         *masterdata.Store
 	Clients             []*SekaiClient
+	SessionStore        sessionstore.Store
+	clientsLock         sync.RWMutex
 	AssetUpdaterServers []utils.HarukiAssetUpdaterInfo
 	Git                 *git.HarukiGitUpdater
 	ClientNo            int
 	ClientNoLock        sync.Mutex
 	Proxy               string
 	Logger              *logger.Logger
+	inflight            singleflight.Group
+	fetchGameAPI        func(ctx context.Context, path string, params map[string]any) (any, int, error)
+	upstreamRequests    atomic.Int64
+	coalescedRequests   atomic.Int64
+	maintenanceUntil    atomic.Int64
+	initErr             atomic.Value
+	updates             sync.WaitGroup
+	reloadLock          sync.Mutex
+	healthPolicy        clientHealthPolicy
+	loginStagger        time.Duration
+	startOnce           sync.Once
+	stop                chan struct{}
+	stopOnce            sync.Once
+}
+
+type initErrorState struct {
+	err error
+}
+
+type SekaiClientManagerStatus struct {
+	Server             utils.HarukiSekaiServerRegion `json:"server"`
+	Clients            int                           `json:"clients"`
+	LoggedInClients    int                           `json:"loggedInClients"`
+	LastLoginError     string                        `json:"lastLoginError,omitempty"`
+	LastLoginErrorAt   *time.Time                    `json:"lastLoginErrorAt,omitempty"`
+	HealthyClients     int                           `json:"healthyClients"`
+	QuarantinedClients int                           `json:"quarantinedClients"`
+	PendingAccounts    []string                      `json:"pendingAccounts,omitempty"`
+	InitError          string                        `json:"initError,omitempty"`
+	UnderMaintenance   bool                          `json:"underMaintenance"`
+	UpstreamRequests   int64                         `json:"upstreamRequests"`
+	CoalescedRequests  int64                         `json:"coalescedRequests"`
+}
+
+type gameAPIResult struct {
+	data     any
+	status   int
+	err      error
+	accounts []string
 }
 
 func NewSekaiClientManager(server utils.HarukiSekaiServerRegion, serverConfig utils.HarukiSekaiServerConfig, assetUpdaterServers []utils.HarukiAssetUpdaterInfo, git *git.HarukiGitUpdater, proxy string, jpSekaiCookieURL string) *SekaiClientManager {
@@ -37,10 +90,14 @@ func NewSekaiClientManager(server utils.HarukiSekaiServerRegion, serverConfig ut
 		Server:              server,
 		ServerConfig:        serverConfig,
 		VersionHelper:       &SekaiVersionHelper{versionFilePath: serverConfig.VersionPath},
+		MasterStore:         masterdata.NewStore(serverConfig.MasterDir),
 		Proxy:               proxy,
 		AssetUpdaterServers: assetUpdaterServers,
 		Git:                 git,
 		Logger:              logger.NewLogger(fmt.Sprintf("SekaiClientManager%s", strings.ToUpper(string(server))), "DEBUG", nil),
+		healthPolicy:        newClientHealthPolicy(serverConfig),
+		loginStagger:        parseLoginStagger(serverConfig),
+		stop:                make(chan struct{}),
 	}
 	if server == utils.HarukiSekaiServerRegionJP {
 		mgr.CookieHelper = &SekaiCookieHelper{url: jpSekaiCookieURL}
@@ -48,8 +105,8 @@ func NewSekaiClientManager(server utils.HarukiSekaiServerRegion, serverConfig ut
 	return mgr
 }
 
-func (mgr *SekaiClientManager) parseAccountFile(path string, data []byte) []SekaiAccountInterface {
-	var accounts []SekaiAccountInterface
+func (mgr *SekaiClientManager) parseAccountFile(path string, data []byte) []accountEntry {
+	var accounts []accountEntry
 	var raw any
 	if err := sonic.Unmarshal(data, &raw); err != nil {
 		mgr.Logger.Warnf("parseAccounts: json decode error %s: %v", path, err)
@@ -59,13 +116,13 @@ func (mgr *SekaiClientManager) parseAccountFile(path string, data []byte) []Seka
 	switch v := raw.(type) {
 	case map[string]any:
 		if acc := mgr.parseAccountMap(v, path, -1); acc != nil {
-			accounts = append(accounts, acc)
+			accounts = append(accounts, mgr.newAccountEntry(path, -1, acc))
 		}
 	case []any:
 		for idx, item := range v {
 			if m, ok := item.(map[string]any); ok {
 				if acc := mgr.parseAccountMap(m, path, idx); acc != nil {
-					accounts = append(accounts, acc)
+					accounts = append(accounts, mgr.newAccountEntry(path, idx, acc))
 				}
 			} else {
 				mgr.Logger.Warnf("parseAccounts: [%s][%d] unexpected array element type: %T", path, idx, item)
@@ -108,8 +165,8 @@ func (mgr *SekaiClientManager) parseAccountMap(m map[string]any, path string, id
 	return nil
 }
 
-func (mgr *SekaiClientManager) parseAccounts() ([]SekaiAccountInterface, error) {
-	var accounts []SekaiAccountInterface
+func (mgr *SekaiClientManager) parseAccounts() ([]accountEntry, error) {
+	var accounts []accountEntry
 
 	err := filepath.Walk(mgr.ServerConfig.AccountDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -131,43 +188,41 @@ func (mgr *SekaiClientManager) parseAccounts() ([]SekaiAccountInterface, error)
 		return nil
 	})
 
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAccounts(accounts); err != nil {
+		return nil, err
+	}
 	if len(accounts) == 0 {
 		mgr.Logger.Warnf("parseAccounts: no accounts parsed from %s", mgr.ServerConfig.AccountDir)
 	}
-
-	return accounts, err
+	return accounts, nil
 }
 
 func (mgr *SekaiClientManager) parseCookies(ctx context.Context) error {
-	if mgr.Server == utils.HarukiSekaiServerRegionJP {
-		var wg sync.WaitGroup
-		errChan := make(chan error, len(mgr.Clients))
-		for _, client := range mgr.Clients {
-			wg.Add(1)
-			go func(c *SekaiClient) {
-				defer wg.Done()
-				if err := c.ParseCookies(ctx); err != nil {
-					mgr.Logger.Warnf("Error parsing cookies: %v", err)
-					errChan <- err
-				}
-			}(client)
+	if mgr.Server != utils.HarukiSekaiServerRegionJP {
+		return nil
+	}
+	_, err, _ := mgr.inflight.Do("\x00cookies", func() (any, error) {
+		cookie, err := mgr.CookieHelper.GetCookies(ctx, mgr.Proxy)
+		if err != nil {
+			mgr.Logger.Warnf("Error parsing cookies: %v", err)
+			return nil, err
 		}
-		wg.Wait()
-		close(errChan)
-
-		for err := range errChan {
-			if err != nil {
-				return err
-			}
+		for _, client := range mgr.clients() {
+			client.setCookie(cookie)
 		}
-	}
-	return nil
+		return nil, nil
+	})
+	return err
 }
 
 func (mgr *SekaiClientManager) parseVersion() error {
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(mgr.Clients))
-	for _, client := range mgr.Clients {
+	clients := mgr.clients()
+	errChan := make(chan error, len(clients))
+	for _, client := range clients {
 		wg.Add(1)
 		go func(c *SekaiClient) {
 			defer wg.Done()
@@ -189,6 +244,16 @@ func (mgr *SekaiClientManager) parseVersion() error {
 }
 
 func (mgr *SekaiClientManager) Init() error {
+	err := mgr.init()
+	mgr.initErr.Store(initErrorState{err: err})
+	mgr.startOnce.Do(func() {
+		go mgr.runHealthProbe()
+		go mgr.runSessionFlush()
+	})
+	return err
+}
+
+func (mgr *SekaiClientManager) init() error {
 	mgr.Logger.Infof("Initializing client manager...")
 
 	accounts, err := mgr.parseAccounts()
@@ -196,85 +261,89 @@ func (mgr *SekaiClientManager) Init() error {
 		return err
 	}
 
-	for _, account := range accounts {
-		client := NewSekaiClient(
-			mgr.Server,
-			mgr.ServerConfig,
-			account,
-			mgr.CookieHelper,
-			mgr.VersionHelper,
-			mgr.Proxy,
-		)
-		mgr.Clients = append(mgr.Clients, client)
+	clients := make([]*SekaiClient, 0, len(accounts))
+	for _, entry := range accounts {
+		client := mgr.newClient(entry)
+		client.health.begin()
+		clients = append(clients, client)
 	}
+	mgr.setClients(clients)
 
-	var wg sync.WaitGroup
-	initErrors := make(chan error, len(mgr.Clients))
-	for _, client := range mgr.Clients {
-		wg.Add(1)
-		go func(c *SekaiClient) {
-			defer wg.Done()
-			if err := c.Init(); err != nil {
-				mgr.Logger.Errorf("Error initializing client: %v", err)
-				initErrors <- err
-			}
-		}(client)
-	}
-	wg.Wait()
-	close(initErrors)
-
-	for err := range initErrors {
-		if err != nil {
-			return err
+	loggedIn, err := mgr.startClients(context.Background(), clients)
+	if pending := len(clients) - loggedIn; pending > 0 {
+		if loggedIn == 0 {
+			return fmt.Errorf("all %d accounts failed to start, retrying in background: %w", pending, err)
 		}
+		mgr.Logger.Warnf("Client manager initialized with %d of %d accounts, %d pending login", loggedIn, len(clients), pending)
+		return nil
 	}
 
-	ctx := context.Background()
-	loginErrors := make(chan error, len(mgr.Clients))
-	for _, client := range mgr.Clients {
-		wg.Add(1)
-		go func(c *SekaiClient) {
-			defer wg.Done()
-			if _, err := c.Login(ctx); err != nil {
-				mgr.Logger.Errorf("Error logging in: %v", err)
-				loginErrors <- err
-			}
-		}(client)
-	}
-	wg.Wait()
-	close(loginErrors)
+	mgr.Logger.Infof("Client manager initialized successfully")
+	return nil
+}
 
-	for err := range loginErrors {
-		if err != nil {
-			return err
+func (mgr *SekaiClientManager) Status() SekaiClientManagerStatus {
+	clients := mgr.clients()
+	status := SekaiClientManagerStatus{
+		Server:           mgr.Server,
+		Clients:          len(clients),
+		UnderMaintenance: mgr.underMaintenance(),
+	}
+	status.UpstreamRequests, status.CoalescedRequests = mgr.CoalesceStats()
+	for _, client := range clients {
+		if client.IsLoggedIn() {
+			status.LoggedInClients++
+		}
+		switch {
+		case client.health.isPending():
+			status.PendingAccounts = append(status.PendingAccounts, client.Account.GetUserId())
+		case client.health.quarantined():
+			status.QuarantinedClients++
+		case client.IsLoggedIn():
+			status.HealthyClients++
+		}
+		if err, at := client.LastLoginError(); err != nil && (status.LastLoginErrorAt == nil || at.After(*status.LastLoginErrorAt)) {
+			status.LastLoginError = err.Error()
+			status.LastLoginErrorAt = &at
 		}
 	}
-
-	mgr.Logger.Infof("Client manager initialized successfully")
-	return nil
+	if state, ok := mgr.initErr.Load().(initErrorState); ok && state.err != nil && status.LoggedInClients == 0 {
+		status.InitError = state.err.Error()
+	}
+	return status
 }
 
 func (mgr *SekaiClientManager) getClient() *SekaiClient {
+	clients := mgr.clients()
 	mgr.ClientNoLock.Lock()
 	defer mgr.ClientNoLock.Unlock()
 
-	if len(mgr.Clients) == 0 {
+	if len(clients) == 0 {
 		return nil
 	}
-	if mgr.ClientNo >= len(mgr.Clients) || mgr.ClientNo < 0 {
+	if mgr.ClientNo >= len(clients) || mgr.ClientNo < 0 {
 		mgr.ClientNo = 0
 	}
-	idx := mgr.ClientNo % len(mgr.Clients)
-	c := mgr.Clients[idx]
-	mgr.ClientNo = (idx + 1) % len(mgr.Clients)
-	return c
+	for i := range clients {
+		idx := (mgr.ClientNo + i) % len(clients)
+		if clients[idx].health.quarantined() {
+			continue
+		}
+		mgr.ClientNo = (idx + 1) % len(clients)
+		return clients[idx]
+	}
+	return nil
 }
 
 func (mgr *SekaiClientManager) Shutdown() error {
+	mgr.stopBackground()
+	mgr.persistSessions(context.Background())
+
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(mgr.Clients))
+	clients := mgr.clients()
+	errChan := make(chan error, len(clients))
 
-	for _, client := range mgr.Clients {
+	for _, client := range clients {
 		wg.Add(1)
 		go func(c *SekaiClient) {
 			defer wg.Done()
@@ -312,6 +381,7 @@ func (mgr *SekaiClientManager) handleUpgradeError() (HarukiSekaiAPIFailedRespons
 
 func (mgr *SekaiClientManager) handleSessionError(ctx context.Context) (HarukiSekaiAPIFailedResponse, int, error) {
 	mgr.Logger.Warnf("%s Server cookies expired, re-parsing...", strings.ToUpper(string(mgr.Server)))
+	metrics.CookieRefreshes.Inc(string(mgr.Server))
 	if err := mgr.parseCookies(ctx); err != nil {
 		resp := HarukiSekaiAPIFailedResponse{
 			Result:  "failed",
@@ -336,7 +406,30 @@ func (mgr *SekaiClientManager) processSuccessResponse(client *SekaiClient, respo
 	return result, statusCode, nil
 }
 
+func (mgr *SekaiClientManager) setMaintenance(on bool) {
+	if !on {
+		mgr.maintenanceUntil.Store(0)
+		return
+	}
+	mgr.maintenanceUntil.Store(time.Now().Add(maintenanceTTL).UnixNano())
+}
+
+func (mgr *SekaiClientManager) underMaintenance() bool {
+	return time.Now().UnixNano() < mgr.maintenanceUntil.Load()
+}
+
 func (mgr *SekaiClientManager) handleGetError(getErr error, retryCount, maxRetries int) (HarukiSekaiAPIFailedResponse, int, error, bool) {
+	var me *UnderMaintenanceError
+	if errors.As(getErr, &me) {
+		mgr.setMaintenance(true)
+		resp := HarukiSekaiAPIFailedResponse{
+			Result:  "failed",
+			Status:  http.StatusServiceUnavailable,
+			Message: fmt.Sprintf("%s Game server is under maintenance.", strings.ToUpper(string(mgr.Server))),
+		}
+		return resp, http.StatusServiceUnavailable, getErr, true
+	}
+
 	var ue *UpgradeRequiredError
 	if errors.As(getErr, &ue) {
 		if resp, status, err := mgr.handleUpgradeError(); err != nil {
@@ -357,8 +450,82 @@ func (mgr *SekaiClientManager) handleGetError(getErr error, retryCount, maxRetri
 	return HarukiSekaiAPIFailedResponse{}, 0, nil, false
 }
 
+func (mgr *SekaiClientManager) gameAPIRequestKey(path string, params map[string]any) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(string(mgr.Server))
+	b.WriteString(" ")
+	b.WriteString(path)
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("\x00%s=%v", k, params[k]))
+	}
+	return b.String()
+}
+
+func (mgr *SekaiClientManager) CoalesceStats() (int64, int64) {
+	return mgr.upstreamRequests.Load(), mgr.coalescedRequests.Load()
+}
+
 func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, params map[string]any) (any, int, error) {
-	if len(mgr.Clients) == 0 {
+	fetch := mgr.getGameAPI
+	if mgr.fetchGameAPI != nil {
+		fetch = mgr.fetchGameAPI
+	}
+	var executed atomic.Bool
+	ch := mgr.inflight.DoChan(mgr.gameAPIRequestKey(path, params), func() (result any, err error) {
+		executed.Store(true)
+		defer func() {
+			if r := recover(); r != nil {
+				mgr.Logger.Errorf("%s panic while requesting %s: %v", strings.ToUpper(string(mgr.Server)), path, r)
+				result, err = nil, fmt.Errorf("panic while requesting %s: %v", path, r)
+			}
+		}()
+		mgr.upstreamRequests.Add(1)
+		served := &ServedBy{}
+		callCtx, cancel := context.WithTimeout(WithServedBy(context.WithoutCancel(ctx), served), gameAPICallTimeout)
+		defer cancel()
+		data, status, err := fetch(callCtx, path, params)
+		return &gameAPIResult{data: data, status: status, err: err, accounts: served.Accounts()}, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		resp := HarukiSekaiAPIFailedResponse{
+			Result:  "failed",
+			Status:  http.StatusGatewayTimeout,
+			Message: "Request cancelled while waiting for game server response",
+		}
+		return resp, http.StatusGatewayTimeout, ctx.Err()
+	case res := <-ch:
+		coalesced := !executed.Load()
+		if coalesced {
+			mgr.coalescedRequests.Add(1)
+			metrics.CoalescedRequests.Inc(string(mgr.Server))
+			mgr.Logger.Debugf("%s coalesced request for %s", strings.ToUpper(string(mgr.Server)), path)
+		}
+		if res.Err != nil {
+			resp := HarukiSekaiAPIFailedResponse{
+				Result:  "failed",
+				Status:  http.StatusInternalServerError,
+				Message: "Internal error while requesting game server",
+			}
+			return resp, http.StatusInternalServerError, res.Err
+		}
+		result := res.Val.(*gameAPIResult)
+		served := servedByFromContext(ctx)
+		for _, account := range result.accounts {
+			served.record(account, coalesced)
+		}
+		return result.data, result.status, result.err
+	}
+}
+
+func (mgr *SekaiClientManager) getGameAPI(ctx context.Context, path string, params map[string]any) (any, int, error) {
+	if mgr.ClientCount() == 0 {
 		resp := HarukiSekaiAPIFailedResponse{
 			Result:  "failed",
 			Status:  http.StatusInternalServerError,
@@ -381,8 +548,10 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 			}
 			return resp, http.StatusInternalServerError, nil
 		}
+		servedByFromContext(ctx).record(client.Account.GetUserId(), false)
 
 		response, getErr := client.Get(ctx, path, params)
+		mgr.recordClientResult(client, getErr)
 
 		if getErr != nil || response == nil {
 			resp, status, err, shouldReturn := mgr.handleGetError(getErr, retryCount, maxRetries)
@@ -391,6 +560,7 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 			}
 
 			retryCount++
+			metrics.UpstreamRetries.Inc(string(mgr.Server), "manager")
 			time.Sleep(retryDelay)
 			continue
 		}
@@ -411,6 +581,7 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 				return resp, status, err
 			}
 			retryCount++
+			metrics.UpstreamRetries.Inc(string(mgr.Server), "manager")
 			time.Sleep(retryDelay)
 			continue
 
@@ -419,10 +590,12 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 				return resp, status, err
 			}
 			retryCount++
+			metrics.UpstreamRetries.Inc(string(mgr.Server), "manager")
 			time.Sleep(retryDelay)
 			continue
 
 		case SekaiApiHttpStatusUnderMaintenance:
+			mgr.setMaintenance(true)
 			resp := HarukiSekaiAPIFailedResponse{
 				Result:  "failed",
 				Status:  http.StatusServiceUnavailable,
@@ -431,6 +604,7 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 			return resp, http.StatusServiceUnavailable, NewUnderMaintenanceError()
 
 		case SekaiApiHttpStatusOk:
+			mgr.setMaintenance(false)
 			result, status, err := mgr.processSuccessResponse(client, response, response.StatusCode())
 			return result, status, err
 
@@ -454,6 +628,9 @@ func (mgr *SekaiClientManager) GetGameAPI(ctx context.Context, path string, para
 
 func (mgr *SekaiClientManager) GetCPMySekaiImage(path string) ([]byte, error) {
 	client := mgr.getClient()
+	if client == nil {
+		return nil, fmt.Errorf("no client available")
+	}
 	return client.GetCPMySekaiImage(path)
 }
 
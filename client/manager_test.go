@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"haruki-sekai-api/utils"
+)
+
+func TestGetGameAPIRecoversPanic(t *testing.T) {
+	mgr := newTestManager(utils.HarukiSekaiServerRegionJP)
+	mgr.fetchGameAPI = func(context.Context, string, map[string]any) (any, int, error) {
+		panic("boom")
+	}
+	data, status, err := mgr.GetGameAPI(context.Background(), "/system", nil)
+	if err == nil || status != http.StatusInternalServerError {
+		t.Fatalf("GetGameAPI = %d, %v, want a 500 error", status, err)
+	}
+	if _, ok := data.(HarukiSekaiAPIFailedResponse); !ok {
+		t.Fatalf("data = %T, want HarukiSekaiAPIFailedResponse", data)
+	}
+}
+
+func TestGetGameAPICountsOnlyWaitersAsCoalesced(t *testing.T) {
+	const callers = 4
+	mgr := newTestManager(utils.HarukiSekaiServerRegionJP)
+	release := make(chan struct{})
+	mgr.fetchGameAPI = func(ctx context.Context, path string, params map[string]any) (any, int, error) {
+		servedByFromContext(ctx).record("100", false)
+		<-release
+		return map[string]any{"ok": true}, http.StatusOK, nil
+	}
+
+	served := make([]*ServedBy, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		served[i] = &ServedBy{}
+		wg.Go(func() {
+			if _, status, err := mgr.GetGameAPI(WithServedBy(context.Background(), served[i]), "/system", nil); err != nil || status != http.StatusOK {
+				t.Errorf("GetGameAPI = %d, %v", status, err)
+			}
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	upstream, coalesced := mgr.CoalesceStats()
+	if upstream != 1 || coalesced != callers-1 {
+		t.Fatalf("upstream, coalesced = %d, %d, want 1, %d", upstream, coalesced, callers-1)
+	}
+	var originating int
+	for _, s := range served {
+		if got := s.Accounts(); len(got) != 1 || got[0] != "100" {
+			t.Fatalf("served accounts = %v", got)
+		}
+		if !s.Coalesced() {
+			originating++
+		}
+	}
+	if originating != 1 {
+		t.Fatalf("%d requests marked as originating, want 1", originating)
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type servedByKey struct{}
+
+type ServedBy struct {
+	mu        sync.Mutex
+	accounts  []string
+	coalesced bool
+}
+
+func WithServedBy(ctx context.Context, served *ServedBy) context.Context {
+	return context.WithValue(ctx, servedByKey{}, served)
+}
+
+func servedByFromContext(ctx context.Context) *ServedBy {
+	served, _ := ctx.Value(servedByKey{}).(*ServedBy)
+	return served
+}
+
+func (s *ServedBy) record(account string, coalesced bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if account != "" && !slices.Contains(s.accounts, account) {
+		s.accounts = append(s.accounts, account)
+	}
+	s.coalesced = s.coalesced || coalesced
+}
+
+func (s *ServedBy) Accounts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.accounts)
+}
+
+func (s *ServedBy) Coalesced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.coalesced
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"haruki-sekai-api/utils"
+	"haruki-sekai-api/utils/sessionstore"
+)
+
+const (
+	sessionFlushInterval = 10 * time.Second
+	sessionStoreTimeout  = 3 * time.Second
+)
+
+func (c *SekaiClient) sessionKey() string {
+	sum := sha256.Sum256([]byte(c.Account.GetToken()))
+	return string(c.Server) + "-" + hex.EncodeToString(sum[:12])
+}
+
+func (c *SekaiClient) loadSession(ctx context.Context) *sessionstore.State {
+	if c.SessionStore == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, sessionStoreTimeout)
+	defer cancel()
+	state, err := c.SessionStore.Load(ctx, c.sessionKey())
+	if err != nil {
+		c.Logger.Warnf("account #%s failed to load saved session: %v", c.Account.GetUserId(), err)
+		return nil
+	}
+	if state == nil || state.SessionToken == "" {
+		return nil
+	}
+	if state.Cookie != "" && c.Server == utils.HarukiSekaiServerRegionJP {
+		c.setCookie(state.Cookie)
+	}
+	return state
+}
+
+func (c *SekaiClient) restoreSession(state *sessionstore.State) {
+	if state.UserID != "" && state.UserID != c.Account.GetUserId() {
+		c.Account.SetUserId(state.UserID)
+	}
+	c.HeaderLock.Lock()
+	c.Headers["X-Session-Token"] = state.SessionToken
+	c.HeaderLock.Unlock()
+	c.loggedIn.Store(true)
+	c.Logger.Infof("account #%s reusing session saved at %s", c.Account.GetUserId(), state.SavedAt.Format(time.RFC3339))
+}
+
+func (c *SekaiClient) persistSession(ctx context.Context) {
+	if c.SessionStore == nil || !c.sessionDirty.Swap(false) {
+		return
+	}
+	c.HeaderLock.Lock()
+	state := sessionstore.State{
+		SessionToken: c.Headers["X-Session-Token"],
+		UserID:       c.Account.GetUserId(),
+		Cookie:       c.Headers["Cookie"],
+		SavedAt:      time.Now(),
+	}
+	c.HeaderLock.Unlock()
+	if state.SessionToken == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, sessionStoreTimeout)
+	defer cancel()
+	if err := c.SessionStore.Save(ctx, c.sessionKey(), state); err != nil {
+		c.sessionDirty.Store(true)
+		c.Logger.Warnf("account #%s failed to save session: %v", c.Account.GetUserId(), err)
+	}
+}
+
+func (c *SekaiClient) deleteSession(ctx context.Context) {
+	if c.SessionStore == nil {
+		return
+	}
+	c.sessionDirty.Store(false)
+	ctx, cancel := context.WithTimeout(ctx, sessionStoreTimeout)
+	defer cancel()
+	if err := c.SessionStore.Delete(ctx, c.sessionKey()); err != nil {
+		c.Logger.Warnf("account #%s failed to delete saved session: %v", c.Account.GetUserId(), err)
+	}
+}
+
+func (mgr *SekaiClientManager) resumeOrLogin(ctx context.Context, c *SekaiClient, state *sessionstore.State, delay time.Duration) error {
+	if state != nil {
+		c.restoreSession(state)
+		mgr.recordClientResult(c, nil)
+		return nil
+	}
+	time.Sleep(delay)
+	if _, err := c.Login(ctx); err != nil {
+		mgr.markPending(c, err)
+		return err
+	}
+	mgr.recordClientResult(c, nil)
+	return nil
+}
+
+func (mgr *SekaiClientManager) persistSessions(ctx context.Context) {
+	for _, c := range mgr.clients() {
+		c.persistSession(ctx)
+	}
+}
+
+func (mgr *SekaiClientManager) runSessionFlush() {
+	ticker := time.NewTicker(sessionFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mgr.stop:
+			return
+		case <-ticker.C:
+			mgr.persistSessions(context.Background())
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"haruki-sekai-api/utils"
+	"haruki-sekai-api/utils/logger"
+	"haruki-sekai-api/utils/sessionstore"
+)
+
+func newTestSessionStore(t *testing.T, ttl time.Duration) *sessionstore.FileStore {
+	t.Helper()
+	store, err := sessionstore.NewFileStore(t.TempDir(), ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func newTestManager(server utils.HarukiSekaiServerRegion) *SekaiClientManager {
+	return &SekaiClientManager{
+		Server: server,
+		Logger: logger.NewLogger("SekaiClientManagerTest", "ERROR", nil),
+	}
+}
+
+func TestResumeSessionFromFileStore(t *testing.T) {
+	ctx := context.Background()
+	var logins atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	store := newTestSessionStore(t, time.Hour)
+	c := newTestClient(t, utils.HarukiSekaiServerRegionJP, srv.URL, srv.URL+"/cookie", 1)
+	c.SessionStore = store
+	c.Headers["X-Data-Version"] = "3.0.0"
+	c.Headers["X-Asset-Version"] = "4.0.0"
+	saved := sessionstore.State{SessionToken: "saved-token", UserID: "1", Cookie: "saved=1", SavedAt: time.Now()}
+	if err := store.Save(ctx, c.sessionKey(), saved); err != nil {
+		t.Fatal(err)
+	}
+
+	state := c.loadSession(ctx)
+	if state == nil {
+		t.Fatal("saved session was not loaded")
+	}
+	if err := newTestManager(c.Server).resumeOrLogin(ctx, c, state, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := logins.Load(); n != 0 {
+		t.Fatalf("logged in %d times, want 0", n)
+	}
+	if !c.IsLoggedIn() {
+		t.Fatal("client is not logged in")
+	}
+	if got := c.sessionToken(); got != saved.SessionToken {
+		t.Fatalf("session token = %q, want %q", got, saved.SessionToken)
+	}
+	if got := c.cookie(); got != saved.Cookie {
+		t.Fatalf("cookie = %q, want %q", got, saved.Cookie)
+	}
+	c.HeaderLock.Lock()
+	dataVersion, assetVersion := c.Headers["X-Data-Version"], c.Headers["X-Asset-Version"]
+	c.HeaderLock.Unlock()
+	if dataVersion != "3.0.0" || assetVersion != "4.0.0" {
+		t.Fatalf("versions = %s/%s, the current versions must be kept", dataVersion, assetVersion)
+	}
+}
+
+func TestExpiredSessionIsNotRestored(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSessionStore(t, time.Hour)
+	c := newTestClient(t, utils.HarukiSekaiServerRegionJP, "http://127.0.0.1:0", "http://127.0.0.1:0/cookie", 1)
+	c.SessionStore = store
+	c.setCookie("current=1")
+	expired := sessionstore.State{SessionToken: "expired-token", Cookie: "expired=1", SavedAt: time.Now().Add(-2 * time.Hour)}
+	if err := store.Save(ctx, c.sessionKey(), expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := c.loadSession(ctx); state != nil {
+		t.Fatalf("loadSession = %+v, want nil for an expired entry", state)
+	}
+	if got := c.cookie(); got != "current=1" {
+		t.Fatalf("cookie = %q, expired cookie must not be applied", got)
+	}
+	if got := c.sessionToken(); got != "" {
+		t.Fatalf("session token = %q, want empty", got)
+	}
+}
+
+func TestLoginPersistsSession(t *testing.T) {
+	cryptor, err := NewSekaiCryptorFromHex(testAESKeyHex, testAESIVHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := cryptor.Pack(map[string]any{"sessionToken": "login-token", "dataVersion": "1.0.0", "assetVersion": "2.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	store := newTestSessionStore(t, 0)
+	c := newTestClient(t, utils.HarukiSekaiServerRegionEN, srv.URL, "", 1)
+	c.SessionStore = store
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := c.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	state, err := store.Load(context.Background(), c.sessionKey())
+	if err != nil || state == nil {
+		t.Fatalf("Load = %v, %v, want the session saved right after login", state, err)
+	}
+	if state.SessionToken != "login-token" || state.UserID != "1" {
+		t.Fatalf("saved state = %+v", state)
+	}
+}
+
+func TestRestoreSessionSetsNuverseUserID(t *testing.T) {
+	c := NewSekaiClient(utils.HarukiSekaiServerRegionTW, utils.HarukiSekaiServerConfig{AESKeyHex: testAESKeyHex, AESIVHex: testAESIVHex},
+		&SekaiAccountNuverse{AccessToken: "access-token"}, nil, &SekaiVersionHelper{}, "")
+	c.restoreSession(&sessionstore.State{SessionToken: "saved-token", UserID: "2002", SavedAt: time.Now()})
+	if got := c.Account.GetUserId(); got != "2002" {
+		t.Fatalf("user id = %q, want 2002", got)
+	}
+	if got := c.sessionToken(); got != "saved-token" {
+		t.Fatalf("session token = %q, want saved-token", got)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"haruki-sekai-api/config"
 	"haruki-sekai-api/utils"
+	"haruki-sekai-api/utils/metrics"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +18,7 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/go-git/go-git/v6"
 	"github.com/go-resty/resty/v2"
+	"github.com/hashicorp/go-version"
 	"github.com/iancoleman/orderedmap"
 )
 
@@ -31,6 +34,54 @@ func (mgr *SekaiClientManager) loadVersionFile() (*orderedmap.OrderedMap, error)
 	return om, nil
 }
 
+func (mgr *SekaiClientManager) GetCurrentVersion() (*orderedmap.OrderedMap, error) {
+	return mgr.loadVersionFile()
+}
+
+func (mgr *SekaiClientManager) GetVersionHistory() ([]*orderedmap.OrderedMap, error) {
+	versionDir := filepath.Dir(mgr.ServerConfig.VersionPath)
+	entries, err := os.ReadDir(versionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	type snapshot struct {
+		version *version.Version
+		path    string
+	}
+	var snapshots []snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || name == filepath.Base(mgr.ServerConfig.VersionPath) {
+			continue
+		}
+		v, err := version.NewVersion(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{version: v, path: filepath.Join(versionDir, name)})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].version.LessThan(snapshots[j].version)
+	})
+
+	history := make([]*orderedmap.OrderedMap, 0, len(snapshots))
+	for _, s := range snapshots {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			mgr.Logger.Warnf("Failed to read version snapshot %s: %v", s.path, err)
+			continue
+		}
+		om := orderedmap.New()
+		if err := sonic.Unmarshal(data, om); err != nil {
+			mgr.Logger.Warnf("Failed to parse version snapshot %s: %v", s.path, err)
+			continue
+		}
+		history = append(history, om)
+	}
+	return history, nil
+}
+
 func (mgr *SekaiClientManager) saveFile(filePath string, data any) error {
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -160,6 +211,10 @@ func (mgr *SekaiClientManager) saveVersionFiles(currentLocalVersion *orderedmap.
 }
 
 func (mgr *SekaiClientManager) CheckSekaiMasterUpdate() {
+	outcome := "error"
+	defer func() {
+		metrics.UpdaterRuns.Inc(string(mgr.Server), "master", outcome)
+	}()
 	ctx := context.Background()
 	var requireUpdateMasterData bool
 	var requireUpdateAsset bool
@@ -176,9 +231,7 @@ func (mgr *SekaiClientManager) CheckSekaiMasterUpdate() {
 		mgr.Logger.Errorf("Sekai updater failed to initialize client, skipped.")
 		return
 	}
-	sekaiClient.APILock.Lock()
-	loginResponse, err := sekaiClient.Login(ctx)
-	sekaiClient.APILock.Unlock()
+	loginResponse, err := sekaiClient.Relogin(ctx)
 	if err != nil {
 		mgr.Logger.Errorf("Sekai updater failed to login: %v", err)
 		return
@@ -198,11 +251,15 @@ func (mgr *SekaiClientManager) CheckSekaiMasterUpdate() {
 	}
 
 	if requireUpdateAsset {
-		go mgr.callAllHarukiAssetUpdater(currentServerAssetVersion, currentServerAssetHash)
+		mgr.updates.Go(func() {
+			mgr.callAllHarukiAssetUpdater(currentServerAssetVersion, currentServerAssetHash)
+		})
 	}
 
 	if requireUpdateMasterData {
-		go mgr.updateMasterData(currentServerDataVersion, splitMasterDataList, currentServerCDNVersion)
+		mgr.updates.Go(func() {
+			mgr.updateMasterData(currentServerDataVersion, splitMasterDataList, currentServerCDNVersion)
+		})
 	}
 
 	if requireUpdateMasterData || requireUpdateAsset {
@@ -217,8 +274,14 @@ func (mgr *SekaiClientManager) CheckSekaiMasterUpdate() {
 		if err := mgr.saveVersionFiles(currentLocalVersion, currentServerDataVersion); err != nil {
 			return
 		}
+		outcome = "updated"
+		return
 	}
+	outcome = "no_update"
+}
 
+func (mgr *SekaiClientManager) WaitUpdates() {
+	mgr.updates.Wait()
 }
 
 func (mgr *SekaiClientManager) saveSplitMasterData(master *orderedmap.OrderedMap) {
@@ -260,6 +323,10 @@ func (mgr *SekaiClientManager) saveSplitMasterData(master *orderedmap.OrderedMap
 }
 
 func (mgr *SekaiClientManager) updateMasterData(dataVersion string, paths []string, cdnVersion int) {
+	outcome := "error"
+	defer func() {
+		metrics.UpdaterRuns.Inc(string(mgr.Server), "master_data", outcome)
+	}()
 	mgr.Logger.Infof("Sekai updater downloading new master data...")
 	sekaiClient := mgr.getClient()
 	if sekaiClient == nil {
@@ -283,6 +350,10 @@ func (mgr *SekaiClientManager) updateMasterData(dataVersion string, paths []stri
 	}
 
 	mgr.Logger.Infof("Sekai updater saved new master data.")
+	if err := mgr.MasterStore.Reload(); err != nil {
+		mgr.Logger.Warnf("Sekai updater failed to rebuild master data indexes: %v", err)
+	}
+	outcome = "updated"
 	repoRoot := filepath.Dir(mgr.ServerConfig.MasterDir)
 	repo, err := git.PlainOpen(repoRoot)
 	if err != nil {
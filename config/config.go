@@ -1,8 +1,8 @@
 package config
 
 import (
+	"fmt"
 	"haruki-sekai-api/utils"
-	harukiLogger "haruki-sekai-api/utils/logger"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -15,20 +15,44 @@ type RedisConfig struct {
 	Password string `yaml:"password"`
 }
 
+type JWTPublicKeyConfig struct {
+	KID  string `yaml:"kid"`
+	Path string `yaml:"path"`
+}
+
+type JWTConfig struct {
+	Algorithms []string             `yaml:"algorithms,omitempty"`
+	PublicKeys []JWTPublicKeyConfig `yaml:"public_keys,omitempty"`
+	JWKSFile   string               `yaml:"jwks_file,omitempty"`
+	Audience   string               `yaml:"audience,omitempty"`
+	Issuer     string               `yaml:"issuer,omitempty"`
+	Leeway     string               `yaml:"leeway,omitempty"`
+	RequireExp bool                 `yaml:"require_exp,omitempty"`
+}
+
+type AuthConfig struct {
+	Mode             string            `yaml:"mode"`
+	StaticTokensFile string            `yaml:"static_tokens_file,omitempty"`
+	RoutePolicies    map[string]string `yaml:"route_policies,omitempty"`
+}
+
 type BackendConfig struct {
-	Host                   string   `yaml:"host"`
-	Port                   int      `yaml:"port"`
-	SSL                    bool     `yaml:"ssl"`
-	SSLCert                string   `yaml:"ssl_cert"`
-	SSLKey                 string   `yaml:"ssl_key"`
-	LogLevel               string   `yaml:"log_level"`
-	MainLogFile            string   `yaml:"main_log_file"`
-	AccessLog              string   `yaml:"access_log"`
-	AccessLogPath          string   `yaml:"access_log_path"`
-	SekaiUserJWTSigningKey string   `yaml:"sekai_user_jwt_signing_key,omitempty"`
-	EnableTrustProxy       bool     `yaml:"enable_trust_proxy"`
-	TrustProxies           []string `yaml:"trusted_proxies"`
-	ProxyHeader            string   `yaml:"proxy_header"`
+	Host                   string    `yaml:"host"`
+	Port                   int       `yaml:"port"`
+	SSL                    bool      `yaml:"ssl"`
+	SSLCert                string    `yaml:"ssl_cert"`
+	SSLKey                 string    `yaml:"ssl_key"`
+	LogLevel               string    `yaml:"log_level"`
+	MainLogFile            string    `yaml:"main_log_file"`
+	AccessLog              string    `yaml:"access_log"`
+	AccessLogPath          string    `yaml:"access_log_path"`
+	SekaiUserJWTSigningKey string    `yaml:"sekai_user_jwt_signing_key,omitempty"`
+	JWT                    JWTConfig `yaml:"jwt"`
+	AdminToken             string    `yaml:"admin_token,omitempty"`
+	EnableTrustProxy       bool      `yaml:"enable_trust_proxy"`
+	TrustProxies           []string  `yaml:"trusted_proxies"`
+	ProxyHeader            string    `yaml:"proxy_header"`
+	EnableMetrics          bool      `yaml:"enable_metrics"`
 }
 
 type GormLoggerConfig struct {
@@ -63,13 +87,52 @@ type GitConfig struct {
 	Password string `yaml:"password,omitempty"`
 }
 
+type RawAPIRouteConfig struct {
+	Path        string                          `yaml:"path"`
+	Servers     []utils.HarukiSekaiServerRegion `yaml:"servers,omitempty"`
+	Params      map[string]string               `yaml:"params,omitempty"`
+	QueryParams map[string]string               `yaml:"query_params,omitempty"`
+}
+
+type RawAPIConfig struct {
+	Enabled bool                `yaml:"enabled"`
+	Routes  []RawAPIRouteConfig `yaml:"routes,omitempty"`
+}
+
+type ResponseCacheConfig struct {
+	Enabled          bool              `yaml:"enabled"`
+	Routes           map[string]string `yaml:"routes,omitempty"`
+	BypassUserIDs    []string          `yaml:"bypass_user_ids,omitempty"`
+	MaxMemoryEntries int               `yaml:"max_memory_entries,omitempty"`
+}
+
+type AuditLogConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Retention     string `yaml:"retention,omitempty"`
+	RetentionCron string `yaml:"retention_cron,omitempty"`
+	BufferSize    int    `yaml:"buffer_size,omitempty"`
+	BatchSize     int    `yaml:"batch_size,omitempty"`
+	FlushInterval string `yaml:"flush_interval,omitempty"`
+}
+
+type SessionStoreConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path,omitempty"`
+	TTL     string `yaml:"ttl,omitempty"`
+}
+
 type Config struct {
 	Proxy               string                                                          `yaml:"proxy"`
 	JPSekaiCookieURL    string                                                          `yaml:"jp_sekai_cookie_url"`
 	Git                 GitConfig                                                       `yaml:"git"`
 	Redis               RedisConfig                                                     `yaml:"redis"`
 	Backend             BackendConfig                                                   `yaml:"backend"`
+	Auth                AuthConfig                                                      `yaml:"auth"`
 	Gorm                GormConfig                                                      `yaml:"gorm"`
+	RawAPI              RawAPIConfig                                                    `yaml:"raw_api"`
+	ResponseCache       ResponseCacheConfig                                             `yaml:"response_cache"`
+	AuditLog            AuditLogConfig                                                  `yaml:"audit_log"`
+	SessionStore        SessionStoreConfig                                              `yaml:"session_store"`
 	AppHashSources      []utils.HarukiSekaiAppHashSource                                `yaml:"apphash_sources"`
 	AssetUpdaterServers []utils.HarukiAssetUpdaterInfo                                  `yaml:"asset_updater_servers"`
 	Servers             map[utils.HarukiSekaiServerRegion]utils.HarukiSekaiServerConfig `yaml:"servers"`
@@ -78,20 +141,21 @@ type Config struct {
 var Version = "v5.0.0-dev"
 var Cfg Config
 
-func init() {
-	logger := harukiLogger.NewLogger("ConfigLoader", "DEBUG", nil)
-	f, err := os.Open("haruki-sekai-configs.yaml")
+const DefaultPath = "haruki-sekai-configs.yaml"
+
+func Load(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		logger.Errorf("Failed to open config file: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to open config file: %w", err)
 	}
 	defer func(f *os.File) {
 		_ = f.Close()
 	}(f)
 
-	decoder := yaml.NewDecoder(f)
-	if err := decoder.Decode(&Cfg); err != nil {
-		logger.Errorf("Failed to parse config: %v", err)
-		os.Exit(1)
+	var cfg Config
+	if err := yaml.NewDecoder(f).Decode(&cfg); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
 	}
+	Cfg = cfg
+	return nil
 }
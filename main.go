@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"haruki-sekai-api/api"
 	"haruki-sekai-api/config"
@@ -14,7 +17,20 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/logger"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	if err := config.Load(config.DefaultPath); err != nil {
+		harukiLogger.NewLogger("ConfigLoader", "DEBUG", nil).Errorf("%v", err)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	serve()
+}
+
+func serve() {
 	var logFile *os.File
 	var loggerWriter io.Writer = os.Stdout
 	if config.Cfg.Backend.MainLogFile != "" {
@@ -63,10 +79,23 @@ func main() {
 		}
 		app.Use(logger.New(logCfg))
 	}
-	api.RegisterRoutes(app)
+	if err := api.RegisterRoutes(app); err != nil {
+		mainLogger.Errorf("failed to register routes: %v", err)
+		os.Exit(1)
+	}
 	appConfig := fiber.ListenConfig{
 		DisableStartupMessage: true,
 	}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		mainLogger.Infof("Shutting down server")
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			mainLogger.Warnf("failed to shut down server gracefully: %v", err)
+		}
+	}()
+
 	addr := fmt.Sprintf("%s:%d", config.Cfg.Backend.Host, config.Cfg.Backend.Port)
 	if config.Cfg.Backend.SSL {
 		mainLogger.Infof("SSL enabled, starting HTTPS server at %s", addr)
@@ -83,4 +112,8 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if err := api.ShutdownAPIUtils(); err != nil {
+		mainLogger.Warnf("error while shutting down: %v", err)
+	}
+	mainLogger.Infof("Server stopped")
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	harukiLogger "haruki-sekai-api/utils/logger"
+	"haruki-sekai-api/utils/metrics"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -227,6 +228,10 @@ func (a *HarukiSekaiAppHashUpdater) SaveNewAppHash(app *utils.HarukiSekaiAppInfo
 }
 
 func (a *HarukiSekaiAppHashUpdater) CheckAppVersion() {
+	outcome := "error"
+	defer func() {
+		metrics.UpdaterRuns.Inc(a.server, "apphash", outcome)
+	}()
 	ctx := context.Background()
 	local, _ := a.GetCurrentAppVersion()
 	remote, _ := a.GetLatestRemoteAppInfo(ctx)
@@ -246,7 +251,9 @@ func (a *HarukiSekaiAppHashUpdater) CheckAppVersion() {
 			return
 		}
 		a.logger.Infof("Saved new app hash")
+		outcome = "updated"
 		return
 	}
+	outcome = "no_update"
 	a.logger.Infof("No new app version found")
 }
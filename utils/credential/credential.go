@@ -0,0 +1,86 @@
+package credential
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2idMemory  = 19 * 1024
+	argon2idTime    = 2
+	argon2idThreads = 1
+	argon2idSaltLen = 16
+	argon2idKeyLen  = 32
+)
+
+var ErrInvalidHash = errors.New("invalid argon2id hash")
+
+type argon2idParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func Hash(plain string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		argon2idMemory, argon2idTime, argon2idThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, argon2idPrefix)
+}
+
+func parseArgon2id(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+	p := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil || p.time == 0 || p.threads == 0 {
+		return nil, ErrInvalidHash
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+	return p, nil
+}
+
+func Verify(plain, stored string) (ok bool, needsRehash bool) {
+	if !IsHashed(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(plain), []byte(stored)) == 1
+		return ok, ok
+	}
+	p, err := parseArgon2id(stored)
+	if err != nil {
+		return false, false
+	}
+	key := argon2.IDKey([]byte(plain), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return false, false
+	}
+	outdated := p.memory != argon2idMemory || p.time != argon2idTime || p.threads != argon2idThreads || len(p.key) != argon2idKeyLen
+	return true, outdated
+}
@@ -0,0 +1,52 @@
+package credential
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hashed, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHashed(hashed) {
+		t.Fatalf("expected argon2id hash, got %q", hashed)
+	}
+	if ok, rehash := Verify("secret", hashed); !ok || rehash {
+		t.Fatalf("Verify(correct) = %v, %v", ok, rehash)
+	}
+	if ok, _ := Verify("wrong", hashed); ok {
+		t.Fatal("Verify accepted a wrong credential")
+	}
+	if other, _ := Hash("secret"); other == hashed {
+		t.Fatal("hashes of the same credential must use different salts")
+	}
+}
+
+func TestVerifyPlaintextNeedsRehash(t *testing.T) {
+	if ok, rehash := Verify("legacy", "legacy"); !ok || !rehash {
+		t.Fatalf("Verify(plaintext) = %v, %v", ok, rehash)
+	}
+	if ok, rehash := Verify("legacy", "other"); ok || rehash {
+		t.Fatalf("Verify(wrong plaintext) = %v, %v", ok, rehash)
+	}
+}
+
+func TestVerifyOutdatedParamsAndMalformed(t *testing.T) {
+	salt := []byte("saltsaltsaltsalt")
+	key := argon2.IDKey([]byte("secret"), salt, 1, 8*1024, 1, argon2idKeyLen)
+	stored := fmt.Sprintf("$argon2id$v=%d$m=%d,t=1,p=1$%s$%s", argon2.Version, 8*1024,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	if ok, rehash := Verify("secret", stored); !ok || !rehash {
+		t.Fatalf("Verify(outdated) = %v, %v", ok, rehash)
+	}
+	for _, malformed := range []string{"$argon2id$broken", "$argon2id$v=19$m=8192,t=1,p=1$c2FsdA$"} {
+		if ok, _ := Verify("secret", malformed); ok {
+			t.Fatalf("Verify accepted malformed hash %q", malformed)
+		}
+	}
+}
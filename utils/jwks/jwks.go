@@ -0,0 +1,220 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+var ErrKeyNotFound = errors.New("no matching public key")
+
+type Key struct {
+	KID    string
+	Alg    string
+	Public crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func ParsePEM(kid string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("no PEM block found for key %q", kid)
+	}
+	var pub crypto.PublicKey
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		pub = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		rsaPub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		pub = rsaPub
+	default:
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+		pub = parsed
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return Key{}, fmt.Errorf("unsupported public key type %T for key %q", pub, kid)
+	}
+	return Key{KID: kid, Public: pub}, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func parseJWK(k jsonWebKey) (Key, error) {
+	key := Key{KID: k.Kid, Alg: k.Alg}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return Key{}, fmt.Errorf("invalid exponent")
+		}
+		key.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return Key{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return Key{}, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		key.Public = pub
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return Key{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("invalid Ed25519 public key")
+		}
+		key.Public = ed25519.PublicKey(x)
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	return key, nil
+}
+
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set jsonWebKeySet
+	if err := sonic.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("jwks key #%d (%s): %w", i, k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func Compatible(pub crypto.PublicKey, alg string) bool {
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		_, ok := pub.(*rsa.PublicKey)
+		return ok
+	case "ES256", "ES384", "ES512":
+		ec, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		want := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}[alg]
+		return ec.Curve.Params().Name == want
+	case "EdDSA":
+		_, ok := pub.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+func NewKeySet(keys ...Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+func (ks *KeySet) Add(keys ...Key) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = append(ks.keys, keys...)
+}
+
+func (ks *KeySet) Len() int {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return len(ks.keys)
+}
+
+func (ks *KeySet) Lookup(kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var candidates []Key
+	for _, k := range ks.keys {
+		if kid != "" && k.KID != kid {
+			continue
+		}
+		if k.Alg != "" && k.Alg != alg {
+			continue
+		}
+		if Compatible(k.Public, alg) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 1 || (kid != "" && len(candidates) > 0) {
+		return candidates[0].Public, nil
+	}
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("multiple keys match %s, token must specify kid", alg)
+	}
+	return nil, ErrKeyNotFound
+}
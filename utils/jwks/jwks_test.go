@@ -0,0 +1,91 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestParsePEMAndLookup(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePEM("rsa-1", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeySet(key, Key{KID: "ed-1", Public: edPub})
+	if pub, err := ks.Lookup("rsa-1", "RS256"); err != nil || pub.(*rsa.PublicKey).N.Cmp(rsaKey.N) != 0 {
+		t.Fatalf("rsa lookup failed: %v", err)
+	}
+	if _, err := ks.Lookup("", "EdDSA"); err != nil {
+		t.Fatalf("kid-less lookup of the only EdDSA key failed: %v", err)
+	}
+	if _, err := ks.Lookup("rsa-1", "ES256"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound for incompatible alg, got %v", err)
+	}
+	if _, err := ks.Lookup("missing", "RS256"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound for unknown kid, got %v", err)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	data := fmt.Sprintf(`{"keys":[
+		{"kty":"EC","kid":"ec-1","crv":"P-256","alg":"ES256","x":%q,"y":%q},
+		{"kty":"OKP","kid":"ed-1","crv":"Ed25519","x":%q},
+		{"kty":"RSA","kid":"enc-1","use":"enc","n":"AQAB","e":"AQAB"}
+	]}`, enc(ecKey.X.FillBytes(make([]byte, 32))), enc(ecKey.Y.FillBytes(make([]byte, 32))), enc(edPub))
+
+	keys, err := ParseJWKS([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 signing keys, got %d", len(keys))
+	}
+	ks := NewKeySet(keys...)
+	pub, err := ks.Lookup("ec-1", "ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.(*ecdsa.PublicKey).Equal(&ecKey.PublicKey) {
+		t.Fatal("EC key mismatch")
+	}
+	if _, err := ks.Lookup("ec-1", "ES384"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound for mismatched alg, got %v", err)
+	}
+
+	bad := fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","x":%q,"y":%q}]}`,
+		enc(big.NewInt(1).FillBytes(make([]byte, 32))), enc(big.NewInt(1).FillBytes(make([]byte, 32))))
+	if _, err := ParseJWKS([]byte(bad)); err == nil {
+		t.Fatal("expected error for point not on curve")
+	}
+}
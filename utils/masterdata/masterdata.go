@@ -0,0 +1,270 @@
+package masterdata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/iancoleman/orderedmap"
+)
+
+var (
+	ErrTableNotFound = errors.New("master table not found")
+	ErrInvalidTable  = errors.New("invalid master table name")
+	ErrNotQueryable  = errors.New("master table is not a list and can not be queried")
+)
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type Query struct {
+	Filters map[string]string
+	Fields  []string
+	Limit   int
+	Offset  int
+}
+
+type Result struct {
+	Total int
+	Rows  []any
+}
+
+type Table struct {
+	Name    string
+	rows    []*orderedmap.OrderedMap
+	raw     *orderedmap.OrderedMap
+	mu      sync.Mutex
+	indexes map[string]map[string][]int
+}
+
+type Store struct {
+	dir    string
+	mu     sync.RWMutex
+	tables map[string]*Table
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, tables: make(map[string]*Table)}
+}
+
+func valueKey(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	case nil:
+		return "null", true
+	}
+	return "", false
+}
+
+func loadTable(dir, name string) (*Table, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrTableNotFound
+		}
+		return nil, err
+	}
+	t := &Table{Name: name, indexes: make(map[string]map[string][]int)}
+	var rows []*orderedmap.OrderedMap
+	if err := sonic.Unmarshal(data, &rows); err == nil {
+		for _, row := range rows {
+			if row != nil {
+				row.SetEscapeHTML(false)
+			}
+		}
+		t.rows = rows
+		t.buildIndex("id")
+		return t, nil
+	}
+	om := orderedmap.New()
+	om.SetEscapeHTML(false)
+	if err := sonic.Unmarshal(data, om); err != nil {
+		return nil, err
+	}
+	t.raw = om
+	return t, nil
+}
+
+func (t *Table) buildIndex(field string) map[string][]int {
+	idx := make(map[string][]int)
+	for i, row := range t.rows {
+		if row == nil {
+			continue
+		}
+		v, ok := row.Get(field)
+		if !ok {
+			continue
+		}
+		if key, ok := valueKey(v); ok {
+			idx[key] = append(idx[key], i)
+		}
+	}
+	t.indexes[field] = idx
+	return idx
+}
+
+func (t *Table) index(field string) map[string][]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if idx, ok := t.indexes[field]; ok {
+		return idx
+	}
+	return t.buildIndex(field)
+}
+
+func (t *Table) indexedFields() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fields := make([]string, 0, len(t.indexes))
+	for field := range t.indexes {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func (t *Table) IsList() bool {
+	return t.raw == nil
+}
+
+func (t *Table) Raw() any {
+	if t.raw != nil {
+		return t.raw
+	}
+	return t.rows
+}
+
+func project(row *orderedmap.OrderedMap, fields []string) *orderedmap.OrderedMap {
+	if len(fields) == 0 {
+		return row
+	}
+	out := orderedmap.New()
+	out.SetEscapeHTML(false)
+	for _, f := range fields {
+		if v, ok := row.Get(f); ok {
+			out.Set(f, v)
+		}
+	}
+	return out
+}
+
+func (t *Table) candidates(filters map[string]string) []int {
+	var best []int
+	first := true
+	for field, value := range filters {
+		matched := t.index(field)[value]
+		if first || len(matched) < len(best) {
+			best = matched
+			first = false
+		}
+	}
+	if first {
+		all := make([]int, len(t.rows))
+		for i := range t.rows {
+			all[i] = i
+		}
+		return all
+	}
+	return best
+}
+
+func (t *Table) matches(row *orderedmap.OrderedMap, filters map[string]string) bool {
+	for field, value := range filters {
+		v, ok := row.Get(field)
+		if !ok {
+			return false
+		}
+		key, ok := valueKey(v)
+		if !ok || key != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Table) Query(q Query) (*Result, error) {
+	if !t.IsList() {
+		return nil, ErrNotQueryable
+	}
+	var matched []*orderedmap.OrderedMap
+	for _, i := range t.candidates(q.Filters) {
+		row := t.rows[i]
+		if row != nil && t.matches(row, q.Filters) {
+			matched = append(matched, row)
+		}
+	}
+
+	result := &Result{Total: len(matched), Rows: []any{}}
+	if q.Offset >= len(matched) {
+		return result, nil
+	}
+	matched = matched[max(q.Offset, 0):]
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	for _, row := range matched {
+		result.Rows = append(result.Rows, project(row, q.Fields))
+	}
+	return result, nil
+}
+
+func (s *Store) Table(name string) (*Table, error) {
+	if !tableNameRe.MatchString(name) {
+		return nil, ErrInvalidTable
+	}
+	s.mu.RLock()
+	t, ok := s.tables[name]
+	s.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tables[name]; ok {
+		return t, nil
+	}
+	t, err := loadTable(s.dir, name)
+	if err != nil {
+		return nil, err
+	}
+	s.tables[name] = t
+	return t, nil
+}
+
+func (s *Store) Reload() error {
+	s.mu.RLock()
+	loaded := make([]*Table, 0, len(s.tables))
+	for _, t := range s.tables {
+		loaded = append(loaded, t)
+	}
+	s.mu.RUnlock()
+
+	tables := make(map[string]*Table, len(loaded))
+	var firstErr error
+	for _, old := range loaded {
+		t, err := loadTable(s.dir, old.Name)
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, ErrTableNotFound) {
+				firstErr = err
+			}
+			continue
+		}
+		for _, field := range old.indexedFields() {
+			t.index(field)
+		}
+		tables[t.Name] = t
+	}
+
+	s.mu.Lock()
+	s.tables = tables
+	s.mu.Unlock()
+	return firstErr
+}
@@ -0,0 +1,62 @@
+package masterdata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+func TestStoreQuery(t *testing.T) {
+	dir := t.TempDir()
+	data := `[{"id":1,"name":"a","unit":"x"},{"id":2,"name":"b","unit":"y"},{"id":3,"name":"c","unit":"y"}]`
+	if err := os.WriteFile(filepath.Join(dir, "cards.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(dir)
+	table, err := store.Table("cards")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := table.Query(Query{Filters: map[string]string{"unit": "y"}, Fields: []string{"name"}, Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || len(res.Rows) != 1 {
+		t.Fatalf("unexpected result: total=%d rows=%d", res.Total, len(res.Rows))
+	}
+	row := res.Rows[0].(*orderedmap.OrderedMap)
+	if keys := row.Keys(); len(keys) != 1 || keys[0] != "name" {
+		t.Fatalf("unexpected projected fields: %v", keys)
+	}
+	if v, _ := row.Get("name"); v != "c" {
+		t.Fatalf("unexpected projected row: %v", v)
+	}
+
+	res, _ = table.Query(Query{Filters: map[string]string{"id": "2"}})
+	if res.Total != 1 {
+		t.Fatalf("expected one row for id=2, got %d", res.Total)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "cards.json"), []byte(`[{"id":2,"unit":"z"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	table, _ = store.Table("cards")
+	res, _ = table.Query(Query{Filters: map[string]string{"unit": "z"}})
+	if res.Total != 1 {
+		t.Fatalf("expected reloaded table to be queried, got %d rows", res.Total)
+	}
+
+	if _, err := store.Table("../cards"); !errors.Is(err, ErrInvalidTable) {
+		t.Fatalf("expected ErrInvalidTable, got %v", err)
+	}
+	if _, err := store.Table("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Fatalf("expected ErrTableNotFound, got %v", err)
+	}
+}
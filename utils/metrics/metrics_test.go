@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "server")
+	c.Inc("jp")
+	c.Add(2, "jp")
+	h := r.NewHistogramVec("test_seconds", "Test histogram.", []float64{0.5, 1}, "path")
+	h.Observe(0.3, `/a"b`)
+	h.Observe(2, `/a"b`)
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_total counter\n",
+		`test_total{server="jp"} 3` + "\n",
+		`test_seconds_bucket{path="/a\"b",le="0.5"} 1` + "\n",
+		`test_seconds_bucket{path="/a\"b",le="+Inf"} 2` + "\n",
+		`test_seconds_sum{path="/a\"b"} 2.3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	got := NormalizePath("/user/{userId}/7485938010999345926/profile?x=1")
+	if got != "/user/{userId}/:id/profile" {
+		t.Fatalf("unexpected normalized path: %s", got)
+	}
+}
@@ -0,0 +1,13 @@
+package metrics
+
+var (
+	UpstreamRequestDuration = NewHistogramVec("haruki_sekai_upstream_request_duration_seconds", "Latency of upstream game API requests.", nil, "server", "path", "status")
+	UpstreamRetries         = NewCounterVec("haruki_sekai_upstream_retries_total", "Retried upstream game API requests.", "server", "layer")
+	SessionRelogins         = NewCounterVec("haruki_sekai_session_relogins_total", "Game account re-logins triggered while serving requests.", "server", "reason")
+	CookieRefreshes         = NewCounterVec("haruki_sekai_cookie_refreshes_total", "Game server cookie refreshes.", "server")
+	ClientExceptions        = NewCounterVec("haruki_sekai_client_exceptions_total", "Sekai client exceptions observed, by exception type.", "server", "type")
+	CoalescedRequests       = NewCounterVec("haruki_sekai_coalesced_requests_total", "Requests served by sharing an identical in-flight upstream request.", "server")
+	UpdaterRuns             = NewCounterVec("haruki_sekai_updater_runs_total", "Updater runs by outcome.", "server", "updater", "outcome")
+	HTTPRequests            = NewCounterVec("haruki_sekai_http_requests_total", "HTTP requests handled, by route.", "method", "route", "status")
+	HTTPRequestDuration     = NewHistogramVec("haruki_sekai_http_request_duration_seconds", "Latency of HTTP requests, by route.", nil, "method", "route")
+)
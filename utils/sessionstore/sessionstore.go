@@ -0,0 +1,123 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+)
+
+type State struct {
+	SessionToken string    `json:"sessionToken"`
+	UserID       string    `json:"userId,omitempty"`
+	Cookie       string    `json:"cookie,omitempty"`
+	SavedAt      time.Time `json:"savedAt"`
+}
+
+type Store interface {
+	Load(ctx context.Context, key string) (*State, error)
+	Save(ctx context.Context, key string, state State) error
+	Delete(ctx context.Context, key string) error
+}
+
+type FileStore struct {
+	dir string
+	ttl time.Duration
+}
+
+func NewFileStore(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, ttl: ttl}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *FileStore) Load(_ context.Context, key string) (*State, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := sonic.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if s.ttl > 0 && time.Since(state.SavedAt) > s.ttl {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (s *FileStore) Save(_ context.Context, key string, state State) error {
+	data, err := sonic.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type RedisStore struct {
+	rdb    *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedisStore(rdb *redis.Client, prefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{rdb: rdb, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisStore) Load(ctx context.Context, key string) (*State, error) {
+	data, err := s.rdb.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := sonic.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, state State) error {
+	data, err := sonic.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, s.prefix+key, data, s.ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, s.prefix+key).Err()
+}
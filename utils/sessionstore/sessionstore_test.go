@@ -0,0 +1,75 @@
+package sessionstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "sessions")
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, err := store.Load(ctx, "missing"); err != nil || state != nil {
+		t.Fatalf("Load(missing) = %v, %v", state, err)
+	}
+
+	saved := State{SessionToken: "token", UserID: "1001", Cookie: "c=1", SavedAt: time.Now()}
+	if err := store.Save(ctx, "jp-1", saved); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load(ctx, "jp-1")
+	if err != nil || state == nil {
+		t.Fatalf("Load = %v, %v", state, err)
+	}
+	if state.SessionToken != saved.SessionToken || state.UserID != saved.UserID || state.Cookie != saved.Cookie {
+		t.Fatalf("Load = %+v, want %+v", state, saved)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the session file, got %d entries", len(entries))
+	}
+}
+
+func TestFileStoreExpired(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, "en-1", State{SessionToken: "token", SavedAt: time.Now().Add(-2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := store.Load(ctx, "en-1"); err != nil || state != nil {
+		t.Fatalf("Load(expired) = %v, %v", state, err)
+	}
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, "tw-1", State{SessionToken: "token", SavedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "tw-1"); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := store.Load(ctx, "tw-1"); err != nil || state != nil {
+		t.Fatalf("Load(deleted) = %v, %v", state, err)
+	}
+	if err := store.Delete(ctx, "tw-1"); err != nil {
+		t.Fatalf("Delete(missing) = %v", err)
+	}
+}
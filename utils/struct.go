@@ -48,6 +48,11 @@ type HarukiSekaiServerConfig struct {
 	MasterDir                string            `yaml:"master_dir,omitempty"`
 	VersionPath              string            `yaml:"version_path,omitempty"`
 	AccountDir               string            `yaml:"account_dir,omitempty"`
+	AccountReloadInterval    string            `yaml:"account_reload_interval,omitempty"`
+	ClientFailureThreshold   int               `yaml:"client_failure_threshold,omitempty"`
+	ClientQuarantine         string            `yaml:"client_quarantine,omitempty"`
+	MaxConcurrentRequests    int               `yaml:"max_concurrent_requests,omitempty"`
+	LoginStagger             string            `yaml:"login_stagger,omitempty"`
 	APIURL                   string            `yaml:"api_url"`
 	NuverseMasterDataURL     string            `yaml:"nuverse_master_data_url,omitempty"`
 	NuverseStructureFilePath string            `yaml:"nuverse_structure_file_path,omitempty"`